@@ -0,0 +1,108 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/fx/fxevent"
+)
+
+// CEF severities of events, by class.
+const (
+	cefSeverityWiring    = 1
+	cefSeverityLifecycle = 3
+	cefSeverityError     = 7
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// WithCEF returns a function that returns a fxevent.Logger writing every event
+// to w as a Common Event Format line. The signature ID of a line is the
+// fxevent type name, its name is the message LogEvent would log, and its
+// extensions are the fields LogEvent would log. Errors have severity 7,
+// lifecycle events 3 and the other events 1.
+func WithCEF(w io.Writer, vendor, product, version string) func() fxevent.Logger {
+	return func() fxevent.Logger {
+		return &cefLogger{
+			w:      w,
+			header: "CEF:0|" + cefHeaderEscaper.Replace(vendor) + "|" + cefHeaderEscaper.Replace(product) + "|" + cefHeaderEscaper.Replace(version) + "|",
+			mapper: &LogrLogger{},
+		}
+	}
+}
+
+// cefLogger is the fxevent.Logger returned by WithCEF.
+type cefLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	header string
+	mapper *LogrLogger
+}
+
+func (c *cefLogger) LogEvent(event fxevent.Event) {
+	typ := eventType(event)
+	severity := cefSeverityWiring
+	if eventCategory(event) == categoryLifecycle {
+		severity = cefSeverityLifecycle
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.mapper.entries(event) {
+		var sb strings.Builder
+		sb.WriteString(c.header)
+		sb.WriteString(cefHeaderEscaper.Replace(typ))
+		sb.WriteString("|")
+		sb.WriteString(cefHeaderEscaper.Replace(e.msg))
+		sb.WriteString("|")
+		if e.isError {
+			fmt.Fprint(&sb, cefSeverityError)
+		} else {
+			fmt.Fprint(&sb, severity)
+		}
+		sb.WriteString("|")
+
+		kvs := e.keysAndValues
+		if e.isError && e.err != nil {
+			kvs = append([]interface{}{"error", e.err.Error()}, kvs...)
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			fmt.Fprintf(&sb, "%v=%s", kvs[i], cefExtensionEscaper.Replace(fieldString(kvs[i+1])))
+		}
+		sb.WriteString("\n")
+
+		_, _ = io.WriteString(c.w, sb.String())
+	}
+}
+
+// fieldString formats the value of a field for text formats, joining type
+// names with commas.
+func fieldString(v interface{}) string {
+	if names, ok := v.([]string); ok {
+		return strings.Join(names, ",")
+	}
+	return fmt.Sprint(v)
+}
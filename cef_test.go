@@ -0,0 +1,98 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+// parseCEF splits a CEF line into its unescaped header fields and extensions.
+func parseCEF(line string) ([]string, map[string]string) {
+	var header []string
+	var field strings.Builder
+	rest := line
+	for len(header) < 7 && rest != "" {
+		c := rest[0]
+		rest = rest[1:]
+		switch {
+		case c == '\\' && rest != "":
+			field.WriteByte(rest[0])
+			rest = rest[1:]
+		case c == '|':
+			header = append(header, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+
+	extensions := map[string]string{}
+	for _, kv := range strings.Fields(rest) {
+		i := strings.Index(kv, "=")
+		for i > 0 && kv[i-1] == '\\' {
+			i += 1 + strings.Index(kv[i+1:], "=")
+		}
+		extensions[kv[:i]] = strings.NewReplacer(`\=`, "=", `\\`, `\`).Replace(kv[i+1:])
+	}
+	return header, extensions
+}
+
+func TestWithCEF(t *testing.T) {
+	var buf bytes.Buffer
+	logger := WithCEF(&buf, "Chaos|Mesh", "fx", "1.0")()
+
+	logger.LogEvent(&fxevent.Provided{
+		ConstructorName: "bytes.NewBuffer()",
+		ModuleName:      "myModule",
+		OutputTypeNames: []string{"*bytes.Buffer"},
+	})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("a=b")})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, `CEF:0|Chaos\|Mesh|fx|1.0|Provided|provided|1|constructor=bytes.NewBuffer() module=myModule type=*bytes.Buffer`, lines[0])
+
+	tests := []struct {
+		wantHeader     []string
+		wantExtensions map[string]string
+	}{
+		{
+			wantHeader:     []string{"CEF:0", "Chaos|Mesh", "fx", "1.0", "Provided", "provided", "1"},
+			wantExtensions: map[string]string{"constructor": "bytes.NewBuffer()", "module": "myModule", "type": "*bytes.Buffer"},
+		},
+		{
+			wantHeader:     []string{"CEF:0", "Chaos|Mesh", "fx", "1.0", "Started", "started", "3"},
+			wantExtensions: map[string]string{},
+		},
+		{
+			wantHeader:     []string{"CEF:0", "Chaos|Mesh", "fx", "1.0", "Stopped", "stop failed", "7"},
+			wantExtensions: map[string]string{"error": "a=b"},
+		},
+	}
+
+	if assert.Len(t, lines, len(tests)) {
+		for i, tt := range tests {
+			header, extensions := parseCEF(lines[i])
+			assert.Equal(t, tt.wantHeader, header)
+			assert.Equal(t, tt.wantExtensions, extensions)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+// Config describes the active configuration of a LogrLogger.
+type Config struct {
+	// LogLevel is the level of non-error events.
+	LogLevel int
+	// ErrorLevel is the level of error events.
+	ErrorLevel int
+	// Features lists the names of the enabled options.
+	Features []string
+}
+
+// Config returns the active configuration of the logger.
+func (l *LogrLogger) Config() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.config()
+}
+
+func (l *LogrLogger) config() Config {
+	features := []string{}
+	add := func(enabled bool, name string) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	add(l.goVersion, "go_version")
+	add(l.version != "", "adapter_version")
+	add(l.deferUntilStarted, "defer_until_started")
+	add(l.adapterStack, "adapter_stack")
+	add(l.startFailStack, "start_failure_stack")
+	add(l.errorSummary, "error_summary")
+	add(l.messageCase != MessageCaseNone, "message_case")
+	add(l.runNumber, "run_number")
+	add(len(l.modulePatterns) != 0, "module_patterns")
+	add(l.sampleRate > 1, "sampling")
+	add(len(l.eventSampling) != 0, "event_sampling")
+	add(l.enabled != nil, "enable_func")
+	add(l.configSnapshot, "config_snapshot")
+	add(l.friendlyErrors, "friendly_errors")
+	add(l.errorTemplater != nil, "error_templater")
+	add(l.traceExtractor != nil, "trace_extractor")
+	add(l.elapsed, "elapsed_since_start")
+	add(l.typedSink != nil, "typed_sink")
+	add(l.startupBudget > 0, "startup_budget")
+	add(l.moduleCount, "module_count")
+	add(l.failureTail > 0, "failure_tail")
+	add(l.errorFields != nil, "error_fields_func")
+	add(l.fqTypeNames != nil, "fq_type_names")
+	add(l.splitMultierror, "split_multierror")
+	add(l.severityTiers != nil, "severity_tiers")
+	add(l.maxPerSecond > 0, "max_events_per_second")
+	add(l.typeDiff != nil, "type_diff")
+	add(l.withoutTypes, "without_type_details")
+	add(l.stopHeartbeat > 0, "stop_stall_heartbeat")
+	add(l.timestampKey != "", "timestamp")
+	add(l.optionCount, "option_count")
+	add(l.announceInit, "announce_init")
+	add(l.owners != nil || l.defaultOwner != "", "ownership")
+	add(l.provideSummary, "module_provide_summary")
+	add(l.symbols != nil, "symbols")
+	add(l.timeline, "timeline")
+	add(l.suppressRepeats, "suppress_repeat_supplies")
+	add(l.failedConstructor, "failed_constructor")
+	add(l.grepPrefix != "", "grep_prefix")
+	add(l.exportedOnly, "exported_provides_only")
+	add(l.hookConcurrency, "hook_concurrency")
+	add(l.cleanShutdownFlag, "clean_shutdown_flag")
+	add(len(l.eventLevels) > 0, "event_levels")
+	add(len(l.categoryNames) > 0, "category_names")
+	add(l.startAttempts, "start_attempts")
+	add(l.keys != nil, "key_names")
+	add(l.messages != nil, "messages")
+	add(l.errorDigest, "error_digest")
+	add(l.eventFilter != nil, "event_filter")
+	add(l.redactor != nil, "redactor")
+	add(len(l.internalModules) > 0, "hide_internal_modules")
+	add(l.withEventKey, "event_key")
+	add(l.runtimeUnit > 0, "numeric_runtime")
+	add(l.aggregateTypes, "aggregated_types")
+	add(l.noInvokeStack, "no_invoke_stack_trace")
+	add(l.dropUnknown, "drop_unknown_events")
+	add(l.observer != nil, "event_observer")
+	add(l.sampler != nil, "sampler")
+	add(l.signalNumber, "signal_number")
+	add(l.runtimePrecision > 0, "runtime_precision")
+	add(l.explicitPrivate, "explicit_private")
+	add(l.contextValues != nil, "context_values")
+	add(l.errorPolicy != nil, "error_policy")
+	add(l.startupSummary, "startup_summary")
+	add(l.typeFormatter != nil, "type_formatter")
+	add(l.phaseField, "phase_field")
+	add(l.errorWrapping, "error_wrapping")
+	add(l.errorChain, "error_chain")
+	add(l.runtimeObserver != nil, "runtime_observer")
+	add(l.invokingLevel != nil, "invoking_level")
+	add(l.writeErrorHandler != nil, "write_error_handler")
+
+	return Config{
+		LogLevel:   l.logLevel,
+		ErrorLevel: l.errorLevel,
+		Features:   features,
+	}
+}
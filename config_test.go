@@ -0,0 +1,53 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestConfig(t *testing.T) {
+	l, _ := newCapturingLogr()
+	logger := WithLogr(l, WithGoVersion(), WithSampling(3))().(*LogrLogger)
+	logger.UseErrorLevel(1)
+
+	assert.Equal(t, Config{
+		ErrorLevel: 1,
+		Features:   []string{"go_version", "sampling"},
+	}, logger.Config())
+}
+
+func TestWithConfigSnapshot(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithConfigSnapshot(), WithRunNumber())()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopping{Signal: os.Interrupt})
+	logger.LogEvent(&fxevent.Stopped{})
+	logger.LogEvent(&fxevent.Started{})
+
+	snapshot := "\"level\"=0 \"msg\"=\"config\" \"log_level\"=0 \"error_level\"=0 \"features\"=[\"run_number\",\"config_snapshot\"]"
+	assert.Equal(t, []string{
+		snapshot + " \"run_number\"=1",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"run_number\"=1",
+		"\"level\"=0 \"msg\"=\"received signal\" \"signal\"=\"INTERRUPT\" \"run_number\"=1",
+		snapshot + " \"run_number\"=2",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"run_number\"=2",
+	}, *lines)
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"go.uber.org/fx/fxevent"
+)
+
+var (
+	numberRegexp         = regexp.MustCompile(`[0-9]+`)
+	failedFunctionRegexp = regexp.MustCompile(`received non-nil error from function "([^"]*)"\.(\S+)`)
+	missingTypeRegexp    = regexp.MustCompile(`missing types?: ([^\n]+)`)
+	suggestionRegexp     = regexp.MustCompile(` \(did you mean [^)]*\)`)
+)
+
+// rewrittenError is an error logged with another message than the original.
+type rewrittenError struct {
+	msg string
+	err error
+}
+
+func (e *rewrittenError) Error() string {
+	return e.msg
+}
+
+func (e *rewrittenError) Unwrap() error {
+	return e.err
+}
+
+// rewriteError returns the error to log in place of err, and whether either
+// WithFriendlyErrors or WithErrorTemplater changed its message. Errors are not
+// compared directly, as some, like dig's missing type errors, are not
+// comparable.
+func (l *LogrLogger) rewriteError(err error) (error, bool) {
+	rewritten, changed := err, false
+	if l.friendlyErrors {
+		if friendly := newFriendlyError(err); friendly != nil {
+			rewritten, changed = friendly, true
+		}
+	}
+	if l.errorTemplater != nil {
+		if msg := l.errorTemplater(rewritten.Error()); msg != rewritten.Error() {
+			rewritten, changed = &rewrittenError{msg: msg, err: err}, true
+		}
+	}
+	return rewritten, changed
+}
+
+// newFriendlyError returns a concise replacement for err, or nil when err is
+// not a missing dependency error.
+//
+// There is no structured way to recognize these errors from an fxevent, so
+// this relies on the "missing type: T" and "missing types: T1; T2" wording
+// dig uses in its error messages, dropping the "(did you mean ...?)" hints.
+// A change of wording in dig silently disables the rewrite.
+func newFriendlyError(err error) error {
+	matches := missingTypeRegexp.FindAllStringSubmatch(err.Error(), -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	types := suggestionRegexp.ReplaceAllString(matches[len(matches)-1][1], "")
+	return &rewrittenError{
+		msg: "missing dependency: " + strings.TrimSpace(types),
+		err: err,
+	}
+}
+
+// contextError is an error wrapped with the context of the event it was
+// reported in by WithErrorWrapping.
+type contextError struct {
+	context string
+	err     error
+}
+
+func (e *contextError) Error() string {
+	return e.context + ": " + e.err.Error()
+}
+
+func (e *contextError) Unwrap() error {
+	return e.err
+}
+
+// wrapError wraps err with the context of the event it was reported in, so
+// that the logged message names what failed while errors.Is and errors.As
+// still reach err.
+func wrapError(event fxevent.Event, err error) error {
+	var context string
+	switch e := event.(type) {
+	case *fxevent.OnStartExecuted:
+		context = fmt.Sprintf("fx OnStart hook %s failed", e.FunctionName)
+	case *fxevent.OnStopExecuted:
+		context = fmt.Sprintf("fx OnStop hook %s failed", e.FunctionName)
+	case *fxevent.Supplied:
+		context = fmt.Sprintf("fx supply of %s failed", e.TypeName)
+	case *fxevent.Provided:
+		context = fmt.Sprintf("fx constructor %s failed", e.ConstructorName)
+	case *fxevent.Replaced:
+		context = fmt.Sprintf("fx replace of %s failed", strings.Join(e.OutputTypeNames, ", "))
+	case *fxevent.Decorated:
+		context = fmt.Sprintf("fx decorator %s failed", e.DecoratorName)
+	case *fxevent.Invoked:
+		context = fmt.Sprintf("fx invoke of %s failed", e.FunctionName)
+	case *fxevent.Started:
+		context = "fx start failed"
+	case *fxevent.Stopped:
+		context = "fx stop failed"
+	case *fxevent.RollingBack:
+		context = "fx start failed, rolling back"
+	case *fxevent.RolledBack:
+		context = "fx rollback failed"
+	case *fxevent.LoggerInitialized:
+		context = fmt.Sprintf("fx logger %s failed to initialize", e.ConstructorName)
+	default:
+		return err
+	}
+	return &contextError{context: context, err: err}
+}
+
+// multiErrors returns the errors combined in err, or nil when err does not
+// combine several errors. Both the Unwrap() []error convention of the standard
+// library and the Errors() []error method of go.uber.org/multierr, which fx
+// uses, are supported. The errors combined in an error wrapped by
+// WithErrorWrapping keep its context.
+func multiErrors(err error) []error {
+	switch err := err.(type) {
+	case *contextError:
+		var errs []error
+		for _, e := range multiErrors(err.err) {
+			errs = append(errs, &contextError{context: err.context, err: e})
+		}
+		return errs
+	case interface{ Unwrap() []error }:
+		return err.Unwrap()
+	case interface{ Errors() []error }:
+		return err.Errors()
+	}
+	return nil
+}
+
+// errorChain returns the messages of the errors err is made of: the innermost
+// error of each branch found by unwrapping err, in order.
+func errorChain(err error) []string {
+	if errs := multiErrors(err); len(errs) > 0 {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, errorChain(err)...)
+		}
+		return messages
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		return errorChain(inner)
+	}
+	return []string{err.Error()}
+}
+
+// failedConstructor returns the best guess at the function whose failure made
+// an invoke fail, or "" when there is none.
+//
+// dig reports the constructor that returned an error as `received non-nil
+// error from function "pkg/path".Name` in the error message, and the innermost
+// such function is the one that failed. When the message does not contain
+// this, the first frame of trace that is not in fx or dig is used instead;
+// trace is where fx.Invoke was called, so this is the invoked function's
+// caller rather than the constructor itself.
+func failedConstructor(err error, trace string) string {
+	if matches := failedFunctionRegexp.FindAllStringSubmatch(err.Error(), -1); len(matches) > 0 {
+		m := matches[len(matches)-1]
+		return m[1] + "." + m[2]
+	}
+	for _, line := range strings.Split(trace, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "go.uber.org/") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// errorFingerprint returns a short hash identifying errors that only differ
+// by the numbers in their message, such as ports, durations or line numbers.
+func errorFingerprint(err error) string {
+	h := fnv.New32a()
+	h.Write([]byte(numberRegexp.ReplaceAllString(err.Error(), "0")))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
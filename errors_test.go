@@ -0,0 +1,329 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/multierr"
+)
+
+func TestWithFriendlyErrors(t *testing.T) {
+	digErr := errors.New(`could not build arguments for function "main".main.func1 (/app/main.go:20): ` +
+		`failed to build *http.Server: missing dependencies for function "main".NewServer (/app/main.go:12): ` +
+		`missing type: *zap.Logger (did you mean to Provide it?)`)
+
+	tests := []struct {
+		name        string
+		give        error
+		wantMessage string
+	}{
+		{
+			name: "MissingType",
+			give: digErr,
+			wantMessage: "\"msg\"=\"invoke failed\" \"error\"=\"missing dependency: *zap.Logger\" \"stack\"=\"\" \"function\"=\"main.run()\" " +
+				"\"raw_error\"=" + strconv.Quote(digErr.Error()),
+		},
+		{
+			name:        "MissingTypes",
+			give:        errors.New("missing dependencies for function \"main\".NewServer: missing types: *zap.Logger; *sql.DB"),
+			wantMessage: "\"msg\"=\"invoke failed\" \"error\"=\"missing dependency: *zap.Logger; *sql.DB\" \"stack\"=\"\" \"function\"=\"main.run()\" \"raw_error\"=\"missing dependencies for function \\\"main\\\".NewServer: missing types: *zap.Logger; *sql.DB\"",
+		},
+		{
+			name:        "OtherError",
+			give:        errors.New("some error"),
+			wantMessage: "\"msg\"=\"invoke failed\" \"error\"=\"some error\" \"stack\"=\"\" \"function\"=\"main.run()\"",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, lines := newCapturingLogr()
+			WithLogr(l, WithFriendlyErrors())().LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: tt.give})
+
+			assert.Equal(t, []string{tt.wantMessage}, *lines)
+		})
+	}
+}
+
+func TestFriendlyErrorUnwrap(t *testing.T) {
+	err := errors.New("missing type: *zap.Logger")
+	assert.ErrorIs(t, newFriendlyError(err), err)
+	assert.Nil(t, newFriendlyError(errors.New("some error")))
+}
+
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("failed with code %d", e.code)
+}
+
+func TestWithErrorFieldsFunc(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorFieldsFunc(func(err error) []interface{} {
+		var codeErr *codeError
+		if errors.As(err, &codeErr) {
+			return []interface{}{"code", codeErr.code}
+		}
+		return nil
+	}))()
+
+	logger.LogEvent(&fxevent.Started{Err: fmt.Errorf("start: %w", &codeError{code: 42})})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"start failed\" \"error\"=\"start: failed with code 42\" \"code\"=42",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\"",
+	}, *lines)
+}
+
+// joinedError combines errors following the Unwrap() []error convention.
+type joinedError []error
+
+func (e joinedError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e joinedError) Unwrap() []error {
+	return e
+}
+
+func TestWithSplitMultierror(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSplitMultierror())()
+
+	logger.LogEvent(&fxevent.Invoked{
+		FunctionName: "main.run()",
+		ModuleName:   "myModule",
+		Err:          joinedError{errors.New("first error"), errors.New("second error")},
+	})
+	logger.LogEvent(&fxevent.RolledBack{Err: multierr.Combine(errors.New("stop error"), errors.New("close error"))})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"invoke failed\" \"error\"=\"first error\" \"stack\"=\"\" \"function\"=\"main.run()\" \"module\"=\"myModule\"",
+		"\"msg\"=\"invoke failed\" \"error\"=\"second error\" \"stack\"=\"\" \"function\"=\"main.run()\" \"module\"=\"myModule\"",
+		"\"msg\"=\"rollback failed\" \"error\"=\"stop error\"",
+		"\"msg\"=\"rollback failed\" \"error\"=\"close error\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\"",
+	}, *lines)
+}
+
+func TestWithErrorTemplater(t *testing.T) {
+	numbers := regexp.MustCompile(`[0-9]+`)
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorTemplater(func(msg string) string {
+		return numbers.ReplaceAllString(msg, "N")
+	}))()
+
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("dial tcp 10.0.0.1:5432: connection refused")})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("dial tcp 10.0.0.2:5433: connection refused")})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	template := "\"msg\"=\"stop failed\" \"error\"=\"dial tcp N.N.N.N:N: connection refused\""
+	assert.Equal(t, []string{
+		template + " \"raw_error\"=\"dial tcp 10.0.0.1:5432: connection refused\"",
+		template + " \"raw_error\"=\"dial tcp 10.0.0.2:5433: connection refused\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\"",
+	}, *lines)
+}
+
+func TestWithErrorTemplaterAndFriendlyErrors(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithFriendlyErrors(), WithErrorTemplater(strings.ToUpper))()
+
+	err := errors.New("could not build arguments: missing type: *zap.Logger")
+	logger.LogEvent(&fxevent.Started{Err: err})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"start failed\" \"error\"=\"MISSING DEPENDENCY: *ZAP.LOGGER\" \"raw_error\"=\"could not build arguments: missing type: *zap.Logger\"",
+	}, *lines)
+}
+
+func TestWithFailedConstructor(t *testing.T) {
+	trace := "go.uber.org/fx.New\n\t/go/pkg/mod/go.uber.org/fx@v1.19.2/app.go:497\n" +
+		"example.com/app.main\n\t/app/main.go:30\n" +
+		"runtime.main\n\t/usr/local/go/src/runtime/proc.go:250"
+
+	tests := []struct {
+		name string
+		give error
+		want string
+	}{
+		{
+			name: "FromError",
+			give: errors.New(`could not build arguments for function "example.com/app".run (/app/main.go:20): ` +
+				`failed to build *app.Server: received non-nil error from function "example.com/app".NewServer (/app/main.go:12): ` +
+				`received non-nil error from function "example.com/app/db".Open (/app/db/db.go:8): connection refused`),
+			want: "example.com/app/db.Open",
+		},
+		{
+			name: "FromTrace",
+			give: errors.New("some error"),
+			want: "example.com/app.main",
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		WithLogr(l, WithFailedConstructor())().LogEvent(&fxevent.Invoked{FunctionName: "run()", Err: tt.give, Trace: trace})
+
+		if assert.Len(t, *lines, 1, tt.name) {
+			assert.True(t, strings.HasSuffix((*lines)[0], " \"failed_constructor\"=\""+tt.want+"\""), tt.name)
+		}
+	}
+}
+
+func TestWithErrorDigest(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithErrorDigest())()
+
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Err: errors.New("dial tcp :8080: connection refused")})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "b()", CallerName: "main()", Err: errors.New("dial tcp :8081: connection refused")})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "c()", CallerName: "main()", Err: errors.New("timeout")})
+	logger.LogEvent(&fxevent.Stopped{})
+
+	var digest [][]interface{}
+	for _, record := range recorder.Records() {
+		if record.Msg == "error digest" {
+			digest = append(digest, record.KeysAndValues)
+		}
+	}
+	if assert.Len(t, digest, 2) {
+		assert.Equal(t, []interface{}{"count", 2, "sample", "dial tcp :8080: connection refused"}, digest[0][2:])
+		assert.Equal(t, []interface{}{"count", 1, "sample", "timeout"}, digest[1][2:])
+		assert.NotEqual(t, digest[0][1], digest[1][1])
+	}
+}
+
+func TestWithErrorDigestLimit(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithErrorDigest())()
+
+	for _, msg := range strings.Split("abcdefghijkl", "") {
+		logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "f()", CallerName: "main()", Err: errors.New(msg)})
+	}
+	logger.LogEvent(&fxevent.Stopped{})
+
+	records := recorder.Records()
+	last := records[len(records)-1]
+	assert.Equal(t, "error digest truncated", last.Msg)
+	assert.Equal(t, []interface{}{"omitted_groups", 2}, last.KeysAndValues)
+	assert.Len(t, records, 12+errorDigestLimit+1)
+}
+
+func TestErrorFingerprint(t *testing.T) {
+	assert.Equal(t, errorFingerprint(errors.New("line 12: bad")), errorFingerprint(errors.New("line 345: bad")))
+	assert.NotEqual(t, errorFingerprint(errors.New("line 12: bad")), errorFingerprint(errors.New("line 12: worse")))
+}
+
+func TestWithErrorWrapping(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := New(&l, WithErrorWrapping(true))
+
+	someError := errors.New("some error")
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "hook.onStart", CallerName: "main.run", Err: someError})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "db.New()", Err: someError})
+	logger.LogEvent(&fxevent.Started{Err: someError})
+
+	var messages []string
+	for _, record := range recorder.Records() {
+		if !record.IsError {
+			continue
+		}
+		assert.ErrorIs(t, record.Err, someError)
+		messages = append(messages, record.Err.Error())
+	}
+	assert.Equal(t, []string{
+		"fx OnStart hook hook.onStart failed: some error",
+		"fx constructor db.New() failed: some error",
+		"fx start failed: some error",
+	}, messages)
+}
+
+func TestWithErrorChain(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorChain(true))()
+
+	logger.LogEvent(&fxevent.Provided{
+		ConstructorName: "db.New()",
+		Err: fmt.Errorf("provide: %w", multierr.Combine(
+			fmt.Errorf("db: %w", errors.New("missing DSN")),
+			joinedError{errors.New("bad pool size"), errors.New("bad timeout")},
+		)),
+	})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"error encountered while applying options\" \"error\"=\"provide: db: missing DSN; bad pool size; bad timeout\" \"errors\"=[\"missing DSN\",\"bad pool size\",\"bad timeout\"]",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\" \"errors\"=[\"some error\"]",
+	}, *lines)
+}
+
+// sliceError is an error that, like dig's missing type errors, is not
+// comparable.
+type sliceError []string
+
+func (e sliceError) Error() string {
+	return strings.Join(e, ", ")
+}
+
+func TestWithErrorTemplaterUncomparableError(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorTemplater(strings.ToUpper))()
+
+	logger.LogEvent(&fxevent.Stopped{Err: sliceError{"a", "b"}})
+	logger.LogEvent(&fxevent.Started{Err: sliceError{"A"}})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"stop failed\" \"error\"=\"A, B\" \"raw_error\"=\"a, b\"",
+		"\"msg\"=\"start failed\" \"error\"=\"A\"",
+	}, *lines)
+}
+
+func TestWithErrorWrappingAndSplitMultierror(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorWrapping(true), WithSplitMultierror())()
+
+	first, second := errors.New("first error"), errors.New("second error")
+	err := joinedError{first, second}
+	logger.LogEvent(&fxevent.Stopped{Err: err})
+
+	assert.Equal(t, []string{
+		"\"msg\"=\"stop failed\" \"error\"=\"fx stop failed: first error\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"fx stop failed: second error\"",
+	}, *lines)
+	assert.Equal(t, []error{first, second}, multiErrors(err))
+}
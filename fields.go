@@ -0,0 +1,38 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "go.uber.org/fx/fxevent"
+
+// EventFields returns the message, key/value pairs and error that a LogrLogger
+// with the default configuration logs for event, without logging anything.
+//
+// Events logged once per output type are reported as a single line with the
+// output types under the "types" key. When an event is logged both as a
+// regular line and as an error, only the error is returned. Events that are
+// not logged at all yield an empty message.
+func EventFields(event fxevent.Event) (msg string, kvs []interface{}, err error) {
+	entries := (&LogrLogger{aggregateTypes: true}).entries(event)
+	if len(entries) == 0 {
+		return "", nil, nil
+	}
+	e := entries[0]
+	for _, entry := range entries {
+		if entry.isError {
+			e = entry
+		}
+	}
+	return e.msg, e.keysAndValues, e.err
+}
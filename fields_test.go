@@ -0,0 +1,211 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestEventFields(t *testing.T) {
+	someError := errors.New("some error")
+
+	tests := []struct {
+		name    string
+		give    fxevent.Event
+		wantMsg string
+		wantKVs []interface{}
+		wantErr error
+	}{
+		{
+			name: "OnStartExecuting",
+			give: &fxevent.OnStartExecuting{
+				FunctionName: "hook.onStart",
+				CallerName:   "bytes.NewBuffer",
+			},
+			wantMsg: "OnStart hook executing",
+			wantKVs: []interface{}{"callee", "hook.onStart", "caller", "bytes.NewBuffer"},
+		},
+		{
+			name: "OnStartExecuted/Error",
+			give: &fxevent.OnStartExecuted{
+				FunctionName: "hook.onStart",
+				CallerName:   "bytes.NewBuffer",
+				Runtime:      3 * time.Millisecond,
+				Err:          someError,
+			},
+			wantMsg: "OnStart hook failed",
+			wantKVs: []interface{}{"callee", "hook.onStart", "caller", "bytes.NewBuffer", "runtime", "3ms"},
+			wantErr: someError,
+		},
+		{
+			name: "OnStopExecuted",
+			give: &fxevent.OnStopExecuted{
+				FunctionName: "hook.onStop",
+				CallerName:   "bytes.NewBuffer",
+				Runtime:      time.Second,
+			},
+			wantMsg: "OnStop hook executed",
+			wantKVs: []interface{}{"callee", "hook.onStop", "caller", "bytes.NewBuffer", "runtime", "1s"},
+		},
+		{
+			name:    "Supplied",
+			give:    &fxevent.Supplied{TypeName: "*bytes.Buffer"},
+			wantMsg: "supplied",
+			wantKVs: []interface{}{"type", "*bytes.Buffer"},
+		},
+		{
+			name: "Provided",
+			give: &fxevent.Provided{
+				ConstructorName: "bytes.NewBuffer()",
+				ModuleName:      "myModule",
+				OutputTypeNames: []string{"*bytes.Buffer", "io.Writer"},
+			},
+			wantMsg: "provided",
+			wantKVs: []interface{}{"constructor", "bytes.NewBuffer()", "module", "myModule", "types", []string{"*bytes.Buffer", "io.Writer"}},
+		},
+		{
+			name:    "Provided/Error",
+			give:    &fxevent.Provided{ModuleName: "myModule", Err: someError},
+			wantMsg: "error encountered while applying options",
+			wantKVs: []interface{}{"module", "myModule"},
+			wantErr: someError,
+		},
+		{
+			name:    "Replaced",
+			give:    &fxevent.Replaced{OutputTypeNames: []string{"*bytes.Buffer"}},
+			wantMsg: "replaced",
+			wantKVs: []interface{}{"types", []string{"*bytes.Buffer"}},
+		},
+		{
+			name:    "Decorated",
+			give:    &fxevent.Decorated{DecoratorName: "decorate()", ModuleName: "myModule", OutputTypeNames: []string{"*bytes.Buffer"}},
+			wantMsg: "decorated",
+			wantKVs: []interface{}{"decorator", "decorate()", "module", "myModule", "types", []string{"*bytes.Buffer"}},
+		},
+		{
+			name:    "Invoking",
+			give:    &fxevent.Invoking{FunctionName: "bytes.NewBuffer()"},
+			wantMsg: "invoking",
+			wantKVs: []interface{}{"function", "bytes.NewBuffer()"},
+		},
+		{
+			name:    "Invoked/Error",
+			give:    &fxevent.Invoked{FunctionName: "bytes.NewBuffer()", Trace: "main.main", Err: someError},
+			wantMsg: "invoke failed",
+			wantKVs: []interface{}{"stack", "main.main", "function", "bytes.NewBuffer()"},
+			wantErr: someError,
+		},
+		{
+			name:    "Invoked",
+			give:    &fxevent.Invoked{FunctionName: "bytes.NewBuffer()"},
+			wantMsg: "",
+		},
+		{
+			name:    "Stopping",
+			give:    &fxevent.Stopping{Signal: os.Interrupt},
+			wantMsg: "received signal",
+			wantKVs: []interface{}{"signal", "INTERRUPT"},
+		},
+		{
+			name:    "Stopped/Error",
+			give:    &fxevent.Stopped{Err: someError},
+			wantMsg: "stop failed",
+			wantErr: someError,
+		},
+		{
+			name:    "RollingBack",
+			give:    &fxevent.RollingBack{StartErr: someError},
+			wantMsg: "start failed, rolling back",
+			wantErr: someError,
+		},
+		{
+			name:    "RolledBack",
+			give:    &fxevent.RolledBack{},
+			wantMsg: "",
+		},
+		{
+			name:    "Started",
+			give:    &fxevent.Started{},
+			wantMsg: "started",
+		},
+		{
+			name:    "Started/Error",
+			give:    &fxevent.Started{Err: someError},
+			wantMsg: "start failed",
+			wantErr: someError,
+		},
+		{
+			name:    "LoggerInitialized",
+			give:    &fxevent.LoggerInitialized{ConstructorName: "fxlogr.WithLogr()"},
+			wantMsg: "initialized custom fxevent.Logger",
+			wantKVs: []interface{}{"function", "fxlogr.WithLogr()"},
+		},
+		{
+			name:    "LoggerInitialized/Error",
+			give:    &fxevent.LoggerInitialized{Err: someError},
+			wantMsg: "custom logger initialization failed",
+			wantErr: someError,
+		},
+		{
+			name:    "Unknown",
+			give:    futureEvent{&fxevent.Started{}},
+			wantMsg: "unhandled fx event",
+			wantKVs: []interface{}{"event_type", "fxlogr.futureEvent"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msg, kvs, err := EventFields(tt.give)
+
+			assert.Equal(t, tt.wantMsg, msg)
+			assert.Equal(t, tt.wantKVs, kvs)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestEventFieldsMatchLogEvent(t *testing.T) {
+	events := []fxevent.Event{
+		&fxevent.OnStartExecuting{FunctionName: "hook.onStart", CallerName: "bytes.NewBuffer"},
+		&fxevent.Supplied{TypeName: "*bytes.Buffer", ModuleName: "myModule"},
+		&fxevent.Invoked{FunctionName: "bytes.NewBuffer()", Err: errors.New("some error")},
+		&fxevent.Stopping{Signal: os.Interrupt},
+	}
+
+	for _, event := range events {
+		recorder := NewLogRecorder()
+		l := recorder.Logger()
+		New(&l).LogEvent(event)
+
+		msg, kvs, err := EventFields(event)
+		if assert.Len(t, recorder.Records(), 1) {
+			record := recorder.Records()[0]
+			assert.Equal(t, msg, record.Msg)
+			assert.Equal(t, kvs, record.KeysAndValues)
+			assert.Equal(t, err, record.Err)
+		}
+	}
+}
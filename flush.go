@@ -0,0 +1,64 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "go.uber.org/multierr"
+
+// Flush flushes the logr sink of l, so that buffered lines are written before
+// the application exits, typically from an OnStop hook:
+//
+//	lc.Append(fx.Hook{OnStop: func(context.Context) error {
+//		return logger.Flush()
+//	}})
+//
+// The sink is flushed when it has a Flush() error, Sync() error or Flush()
+// method; Flush is a no-op otherwise. Loggers created with WithSinks flush
+// the writers of their sinks the same way.
+func (l *LogrLogger) Flush() error {
+	if l.Logger == nil {
+		return nil
+	}
+	return flush(l.Logger.GetSink())
+}
+
+// flush calls the flush or sync method of v, if any.
+func flush(v interface{}) error {
+	switch f := v.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Sync() error }:
+		return f.Sync()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
+
+// Flush flushes all sinks, returning their errors combined.
+func (m multiSink) Flush() error {
+	var err error
+	for _, s := range m {
+		err = multierr.Append(err, flush(s))
+	}
+	return err
+}
+
+// Flush flushes the writer of the sink.
+func (s *writerSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return flush(s.sink.Writer)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+// flushingSink is a logr.LogSink counting calls to Flush.
+type flushingSink struct {
+	logr.LogSink
+	flushes int
+	err     error
+}
+
+func (s *flushingSink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+func TestFlush(t *testing.T) {
+	sink := &flushingSink{LogSink: funcr.New(func(_, _ string) {}, funcr.Options{}).GetSink()}
+	l := logr.New(sink)
+	logger := New(&l)
+
+	assert.NoError(t, logger.Flush())
+	assert.Equal(t, 1, sink.flushes)
+
+	sink.err = errors.New("some error")
+	assert.EqualError(t, logger.Flush(), "some error")
+}
+
+func TestFlushNoop(t *testing.T) {
+	l, _ := newCapturingLogr()
+	assert.NoError(t, New(l).Flush())
+	assert.NoError(t, (&LogrLogger{}).Flush())
+}
+
+func TestFlushSinks(t *testing.T) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	logger := WithSinks(Sink{Writer: w})().(*LogrLogger)
+
+	logger.LogEvent(&fxevent.Started{})
+	assert.Empty(t, out.String())
+
+	assert.NoError(t, logger.Flush())
+	assert.Equal(t, "level=0 msg=started total_runtime=0s\n", out.String())
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fxotel records fx lifecycle events on an OpenTelemetry span, to
+// trace the cold start of an application. It is a Go module of its own, apart
+// from fxlogr, so that only its users depend on OpenTelemetry.
+package fxotel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx/fxevent"
+
+	fxlogr "github.com/chaos-mesh/fx-logr"
+)
+
+// SpanLogger is a fxevent.Logger recording lifecycle events on a span.
+type SpanLogger struct {
+	span trace.Span
+}
+
+var _ fxevent.Logger = (*SpanLogger)(nil)
+
+// New returns a SpanLogger recording on span.
+func New(span trace.Span) *SpanLogger {
+	return &SpanLogger{span: span}
+}
+
+// WithSpanFromContext returns a function that returns a fxevent.Logger
+// recording on the span of ctx, for use with fx.WithLogger.
+func WithSpanFromContext(ctx context.Context) func() fxevent.Logger {
+	return func() fxevent.Logger {
+		return New(trace.SpanFromContext(ctx))
+	}
+}
+
+// LogEvent records the hooks, start and stop events as span events named
+// after the message fxlogr logs for them, with the fields fxlogr logs as
+// attributes, such as "callee", "caller" and "runtime". Errors are also
+// recorded on the span, whose status is set to Error. Other events are
+// ignored.
+func (s *SpanLogger) LogEvent(event fxevent.Event) {
+	switch event.(type) {
+	case *fxevent.OnStartExecuting, *fxevent.OnStartExecuted,
+		*fxevent.OnStopExecuting, *fxevent.OnStopExecuted,
+		*fxevent.Started, *fxevent.Stopping, *fxevent.Stopped,
+		*fxevent.RollingBack, *fxevent.RolledBack:
+	default:
+		return
+	}
+
+	msg, kvs, err := fxlogr.EventFields(event)
+	if msg == "" {
+		return
+	}
+	attrs := make([]attribute.KeyValue, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		attrs = append(attrs, attribute.String(fmt.Sprint(kvs[i]), fmt.Sprint(kvs[i+1])))
+	}
+	if err != nil {
+		s.span.RecordError(err, trace.WithAttributes(attrs...))
+		s.span.SetStatus(codes.Error, msg)
+		return
+	}
+	s.span.AddEvent(msg, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithSpanFromContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "startup")
+
+	app := fx.New(
+		fx.WithLogger(WithSpanFromContext(ctx)),
+		fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{OnStart: func(context.Context) error { return nil }})
+			lc.Append(fx.Hook{OnStart: func(context.Context) error { return errors.New("some error") }})
+		}),
+	)
+	require.Error(t, app.Start(ctx))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	var names []string
+	for _, event := range spans[0].Events() {
+		names = append(names, event.Name)
+	}
+	assert.Equal(t, []string{
+		"OnStart hook executing",
+		"OnStart hook executed",
+		"OnStart hook executing",
+		"exception",
+		"exception",
+		"exception",
+	}, names)
+
+	executed := spans[0].Events()[1]
+	keys := make(map[string]string)
+	for _, attr := range executed.Attributes {
+		keys[string(attr.Key)] = attr.Value.AsString()
+	}
+	assert.Contains(t, keys, "callee")
+	assert.Contains(t, keys, "caller")
+	assert.Contains(t, keys, "runtime")
+
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "start failed", spans[0].Status().Description)
+}
+
+func TestNewIgnoresWiringEvents(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, span := provider.Tracer("test").Start(context.Background(), "startup")
+
+	app := fx.New(fx.WithLogger(func() fxevent.Logger { return New(span) }), fx.Provide(func() int { return 1 }))
+	require.NoError(t, app.Err())
+	span.End()
+
+	assert.Empty(t, recorder.Ended()[0].Events())
+}
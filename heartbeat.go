@@ -0,0 +1,100 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"time"
+
+	"go.uber.org/fx/fxevent"
+)
+
+// runningHook is an OnStop hook that has started executing.
+type runningHook struct {
+	callee string
+	caller string
+	start  time.Time
+	// beats is the number of heartbeat intervals the hook was last logged
+	// as running for.
+	beats int
+}
+
+// trackStopHook records the OnStop hooks in flight for
+// WithStopStallHeartbeat, and starts or stops watching them. The hooks that
+// stalled since the last event are logged first, so the heartbeat follows the
+// clock of WithClock between ticks.
+func (l *LogrLogger) trackStopHook(event fxevent.Event) {
+	l.logStalledStopHooks()
+	switch e := event.(type) {
+	case *fxevent.OnStopExecuting:
+		l.stopHooks = append(l.stopHooks, runningHook{
+			callee: e.FunctionName,
+			caller: e.CallerName,
+			start:  l.now(),
+		})
+		if l.stopWatch == nil {
+			l.stopWatch = make(chan struct{})
+			go l.watchStopHooks(l.stopWatch)
+		}
+	case *fxevent.OnStopExecuted:
+		for i, h := range l.stopHooks {
+			if h.callee == e.FunctionName && h.caller == e.CallerName {
+				l.stopHooks = append(l.stopHooks[:i], l.stopHooks[i+1:]...)
+				break
+			}
+		}
+		if len(l.stopHooks) == 0 && l.stopWatch != nil {
+			close(l.stopWatch)
+			l.stopWatch = nil
+		}
+	}
+}
+
+// watchStopHooks checks for stalled OnStop hooks at every heartbeat interval
+// until done is closed, for when no event comes in.
+func (l *LogrLogger) watchStopHooks(done <-chan struct{}) {
+	ticker := time.NewTicker(l.stopHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			l.logStalledStopHooks()
+			l.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// logStalledStopHooks logs the OnStop hooks that have been running for
+// another heartbeat interval since they were last logged.
+func (l *LogrLogger) logStalledStopHooks() {
+	now := l.now()
+	for i := range l.stopHooks {
+		h := &l.stopHooks[i]
+		running := now.Sub(h.start)
+		beats := int(running / l.stopHeartbeat)
+		if beats <= h.beats {
+			continue
+		}
+		h.beats = beats
+		l.logAside("stop hook still running",
+			l.keyNames().Callee, h.callee,
+			l.keyNames().Caller, h.caller,
+			"running_for", running.String(),
+		)
+	}
+}
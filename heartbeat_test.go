@@ -0,0 +1,69 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithStopStallHeartbeat(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithStopStallHeartbeat(time.Hour), WithClock(clock.Now))().(*LogrLogger)
+
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "hook.onStop1", CallerName: "bytes.NewBuffer"})
+	clock.Add(30 * time.Minute)
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "hook.onStop2", CallerName: "bytes.NewBuffer"})
+	clock.Add(45 * time.Minute)
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop2", CallerName: "bytes.NewBuffer", Runtime: 45 * time.Minute})
+	clock.Add(10 * time.Minute)
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "hook.onStop3", CallerName: "bytes.NewBuffer"})
+	clock.Add(time.Hour)
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop1", CallerName: "bytes.NewBuffer", Runtime: 145 * time.Minute})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop3", CallerName: "bytes.NewBuffer", Runtime: time.Hour})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"OnStop hook executing\" \"callee\"=\"hook.onStop1\" \"caller\"=\"bytes.NewBuffer\"",
+		"\"level\"=0 \"msg\"=\"OnStop hook executing\" \"callee\"=\"hook.onStop2\" \"caller\"=\"bytes.NewBuffer\"",
+		"\"level\"=0 \"msg\"=\"stop hook still running\" \"callee\"=\"hook.onStop1\" \"caller\"=\"bytes.NewBuffer\" \"running_for\"=\"1h15m0s\"",
+		"\"level\"=0 \"msg\"=\"OnStop hook executed\" \"callee\"=\"hook.onStop2\" \"caller\"=\"bytes.NewBuffer\" \"runtime\"=\"45m0s\"",
+		"\"level\"=0 \"msg\"=\"OnStop hook executing\" \"callee\"=\"hook.onStop3\" \"caller\"=\"bytes.NewBuffer\"",
+		"\"level\"=0 \"msg\"=\"stop hook still running\" \"callee\"=\"hook.onStop1\" \"caller\"=\"bytes.NewBuffer\" \"running_for\"=\"2h25m0s\"",
+		"\"level\"=0 \"msg\"=\"stop hook still running\" \"callee\"=\"hook.onStop3\" \"caller\"=\"bytes.NewBuffer\" \"running_for\"=\"1h0m0s\"",
+		"\"level\"=0 \"msg\"=\"OnStop hook executed\" \"callee\"=\"hook.onStop1\" \"caller\"=\"bytes.NewBuffer\" \"runtime\"=\"2h25m0s\"",
+		"\"level\"=0 \"msg\"=\"OnStop hook executed\" \"callee\"=\"hook.onStop3\" \"caller\"=\"bytes.NewBuffer\" \"runtime\"=\"1h0m0s\"",
+	}, *lines)
+	assert.Nil(t, logger.stopWatch)
+}
+
+func TestWithStopStallHeartbeatTicks(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithStopStallHeartbeat(time.Millisecond))().(*LogrLogger)
+
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "hook.onStop", CallerName: "bytes.NewBuffer"})
+	assert.Eventually(t, func() bool {
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		return len(*lines) > 1
+	}, time.Second, time.Millisecond)
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop", CallerName: "bytes.NewBuffer"})
+
+	assert.True(t, strings.Contains((*lines)[1], "stop hook still running"))
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "strings"
+
+// Named V-levels, for use with UseLogLevel, UseErrorLevel and the level
+// options. logr has no levels above info, so there is no warn or error level;
+// errors are logged with logr's Error regardless of their V-level.
+const (
+	// LevelInfo is logr's default V-level.
+	LevelInfo = 0
+	// LevelDebug is the V-level for details useful when debugging.
+	LevelDebug = 1
+	// LevelTrace is the V-level for the most verbose output.
+	LevelTrace = 2
+)
+
+var levelNames = map[string]int{
+	"info":  LevelInfo,
+	"debug": LevelDebug,
+	"trace": LevelTrace,
+}
+
+// ParseLevel returns the V-level named name, which is one of "info", "debug"
+// and "trace" in any case. It reports false for other names.
+func ParseLevel(name string) (int, bool) {
+	level, ok := levelNames[strings.ToLower(name)]
+	return level, ok
+}
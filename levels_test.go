@@ -0,0 +1,58 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantLevel int
+		wantOK    bool
+	}{
+		{name: "info", wantLevel: LevelInfo, wantOK: true},
+		{name: "debug", wantLevel: LevelDebug, wantOK: true},
+		{name: "TRACE", wantLevel: LevelTrace, wantOK: true},
+		{name: "verbose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, ok := ParseLevel(tt.name)
+			assert.Equal(t, tt.wantLevel, level)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestWithNamedLevel(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := New(&l, WithNamedLevel("debug"))
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	logger = New(&l, WithLogLevel(LevelTrace), WithNamedLevel("verbose"))
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	var levels []int
+	for _, record := range recorder.Records() {
+		levels = append(levels, record.Level)
+	}
+	assert.Equal(t, []int{LevelDebug, LevelTrace}, levels)
+}
@@ -15,7 +15,18 @@
 package fxlogr
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-logr/logr"
 	"go.uber.org/fx/fxevent"
@@ -24,243 +35,1442 @@ import (
 type LogrLogger struct {
 	Logger *logr.Logger
 
-	logLevel   int
-	errorLevel int
+	logLevel    int
+	errorLevel  int
+	eventLevels map[string]int
+
+	goVersion    bool
+	adapterStack bool
+
+	mu                sync.Mutex
+	deferUntilStarted bool
+	started           bool
+	buffered          []bufferedEvent
+
+	errorSummary bool
+	messageCase  MessageCase
+	runNumber    bool
+
+	modulePatterns    []modulePattern
+	sampleRate        int
+	eventSampling     map[string]int
+	aggregateTypes    bool
+	enabled           func() bool
+	bypassGate        bool
+	configSnapshot    bool
+	friendlyErrors    bool
+	errorTemplater    func(string) string
+	traceExtractor    func() (traceID, spanID string)
+	elapsed           bool
+	clock             func() time.Time
+	version           string
+	typedSink         func(fxevent.Event)
+	startupBudget     time.Duration
+	startFailStack    bool
+	moduleCount       bool
+	failureTail       int
+	errorFields       func(error) []interface{}
+	fqTypeNames       func(string) string
+	splitMultierror   bool
+	severityTiers     map[string]int
+	maxPerSecond      int
+	throttleErrors    bool
+	typeDiff          TypeDiffResolver
+	withoutTypes      bool
+	stopHeartbeat     time.Duration
+	timestampKey      string
+	timestampLayout   string
+	optionCount       bool
+	announceInit      bool
+	announced         bool
+	owners            map[string]string
+	defaultOwner      string
+	provideSummary    bool
+	symbols           *Symbols
+	timeline          bool
+	suppressRepeats   bool
+	failedConstructor bool
+	grepPrefix        string
+	exportedOnly      bool
+	hookConcurrency   bool
+	cleanShutdownFlag bool
+	categoryNames     map[string]string
+	startAttempts     bool
+	keys              *KeyNames
+	messages          *Messages
+	errorDigest       bool
+	eventFilter       func(fxevent.Event) bool
+	redactor          func(key, value string) string
+	internalModules   []string
+	withEventKey      bool
+	runtimeUnit       time.Duration
+	noInvokeStack     bool
+	dropUnknown       bool
+	observer          func(event fxevent.Event, isError bool)
+	runtimeObserver   func(eventType, name string, d time.Duration)
+	invokingLevel     *int
+	sampler           Sampler
+	signalNumber      bool
+	runtimePrecision  time.Duration
+	explicitPrivate   bool
+	contextValues     func(context.Context) []interface{}
+	errorPolicy       func(fxevent.Event) (useError bool, level int)
+	startupSummary    bool
+	typeFormatter     func(string) string
+	phaseField        bool
+	errorWrapping     bool
+	errorChain        bool
+	writeErrorHandler func(error)
+
+	runs         int
+	sampleN      int
+	sampleCounts map[string]int
+	tokens       float64
+	lastRefill   time.Time
+	stopHooks    []runningHook
+	supplied     map[string]struct{}
+	stopWatch    chan struct{}
+	// dropped counts the events dropped by WithMaxEventsPerSecond since the
+	// last logged line.
+	dropped int
+	// eventType is the fxevent type name of the event being logged.
+	eventType string
+	// eventModule is the module of the event being logged.
+	eventModule string
+	// eventName is the logger name of the category of the event being
+	// logged, set by WithCategoryNames.
+	eventName string
+	// ctxValues are the values extracted from the context of the event being
+	// logged, set by WithContextValues.
+	ctxValues []interface{}
+	// emittedAt is when the event being logged was received, when it was held
+	// back by WithDeferUntilStarted. It is used as the current time.
+	emittedAt time.Time
+	// eventKey is the key of the event being logged, set by WithEventKey.
+	eventKey string
+	// eventLine is the index of the next line logged for the event being
+	// logged, which makes the key of each of its lines unique.
+	eventLine int
+	// errorAsInfo is set when the error lines of the event being logged are
+	// logged with Info rather than Error, as decided by WithErrorPolicy.
+	errorAsInfo bool
+	// eventLevel is the level of non-error logs for the event being logged.
+	eventLevel int
+	// captureStack is set when errors of the event being logged get an
+	// adapter stack.
+	captureStack bool
+	// sampledAt is the sampling rate of the event being logged, or 0 when it
+	// was not sampled.
+	sampledAt int
+
+	run runState
+}
+
+// runState holds what is tracked between the first event of a run and the
+// Stopped or failed Started event that ends it.
+type runState struct {
+	active     bool
+	start      time.Time
+	errorCount int
+	errors     []string
+	modules    map[string]struct{}
+	tail       []tailEvent
+	options    int
+	timeline   []TimelineEntry
+	// repeatedSupplies counts the Supplied events dropped by
+	// WithSuppressRepeatSupplies.
+	repeatedSupplies int
+	// provides lists the constructors provided by each module, in the order
+	// modules were first seen.
+	provides []moduleProvides
+	// errorGroups are the errors of the run grouped by fingerprint, for
+	// WithErrorDigest, in the order they were first seen.
+	errorGroups []errorGroup
+	// hooksStart is when the first OnStart hook of the run started executing.
+	hooksStart time.Time
+	// provided, decorated and invoked count the events of these types, for
+	// WithStartupSummary.
+	provided, decorated, invoked int
+	// events counts the events of the run.
+	events int
+	// failed is set once an event of the run reported an error.
+	failed bool
+	// hooks is the number of hooks executing, for WithHookConcurrency.
+	hooks int
+	// optionsLogged is set once the count of WithOptionCount was logged.
+	optionsLogged bool
+	tailLogged    bool
+}
+
+// errorGroup is a group of errors with the same fingerprint.
+type errorGroup struct {
+	fingerprint string
+	count       int
+	sample      string
 }
 
+// errorDigestLimit caps the number of error groups reported by
+// WithErrorDigest.
+const errorDigestLimit = 10
+
+// errorSummaryLimit caps the number of error messages reported by
+// WithErrorSummary.
+const errorSummaryLimit = 10
+
 var _ fxevent.Logger = (*LogrLogger)(nil)
 
-// UseLogLevel sets the log level for log events.
+// UseLogLevel sets the log level for log events. It is safe to call while
+// events are logged.
 func (l *LogrLogger) UseLogLevel(level int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.logLevel = level
 }
 
-// UseErrorLevel sets the log level for error events.
+// UseEventLevel sets the log level for log events of the given types, keyed
+// by the name of the fxevent type, such as "OnStartExecuting". Events of
+// other types are logged at the log level. Module patterns set with
+// WithModulePattern take precedence.
+func (l *LogrLogger) UseEventLevel(levels map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.eventLevels = make(map[string]int, len(levels))
+	for typ, level := range levels {
+		l.eventLevels[typ] = level
+	}
+}
+
+// UseErrorLevel sets the log level for error events. It is safe to call while
+// events are logged.
 func (l *LogrLogger) UseErrorLevel(level int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.errorLevel = level
 }
 
 func (l *LogrLogger) logEvent(msg string, keysAndValues ...interface{}) {
-	l.Logger.V(l.logLevel).Info(msg, keysAndValues...)
+	if sev, ok := l.severityTiers[l.eventType]; ok {
+		keysAndValues = append(keysAndValues, "sev", sev)
+	}
+	l.logger().V(l.eventLevel).Info(l.message(msg, false), l.withFields(keysAndValues)...)
 }
 
 func (l *LogrLogger) logError(err error, msg string, keysAndValues ...interface{}) {
-	l.Logger.V(l.errorLevel).Error(err, msg, keysAndValues...)
+	if l.splitMultierror {
+		if errs := multiErrors(err); len(errs) > 1 {
+			for _, err := range errs {
+				l.logError(err, msg, append([]interface{}(nil), keysAndValues...)...)
+			}
+			return
+		}
+	}
+	if l.errorSummary && err != nil {
+		l.run.errorCount++
+		if len(l.run.errors) < errorSummaryLimit {
+			l.run.errors = append(l.run.errors, err.Error())
+		}
+	}
+	if l.errorDigest && err != nil {
+		l.recordError(err)
+	}
+	if l.errorFields != nil && err != nil {
+		keysAndValues = append(keysAndValues, l.errorFields(err)...)
+	}
+	if l.errorChain && err != nil {
+		keysAndValues = append(keysAndValues, "errors", errorChain(err))
+	}
+	if err != nil {
+		if rewritten, ok := l.rewriteError(err); ok {
+			keysAndValues = append(keysAndValues, "raw_error", err.Error())
+			err = rewritten
+		}
+	}
+	if l.severityTiers != nil {
+		sev, ok := l.severityTiers[errorSeverityKey]
+		if !ok {
+			sev = defaultErrorSeverity
+		}
+		keysAndValues = append(keysAndValues, "sev", sev)
+	}
+	if l.captureStack && !hasKey(keysAndValues, l.keyNames().Stack) {
+		keysAndValues = append(keysAndValues, "adapter_stack", callerStack(2))
+	}
+	if l.errorAsInfo {
+		if err != nil {
+			keysAndValues = append([]interface{}{"error", err.Error()}, keysAndValues...)
+		}
+		l.logger().V(l.errorLevel).Info(l.message(msg, true), l.withFields(keysAndValues)...)
+		return
+	}
+	l.logger().V(l.errorLevel).Error(err, l.message(msg, true), l.withFields(keysAndValues)...)
+}
+
+// levelFor returns the level of non-error logs for event.
+func (l *LogrLogger) levelFor(event fxevent.Event) int {
+	if module := moduleName(event); module != "" {
+		for _, p := range l.modulePatterns {
+			if ok, _ := path.Match(p.pattern, module); ok {
+				return p.level
+			}
+		}
+	}
+	if level, ok := l.eventLevels[eventType(event)]; ok {
+		return level
+	}
+	if _, ok := event.(*fxevent.Invoking); ok && l.invokingLevel != nil {
+		return *l.invokingLevel
+	}
+	return l.logLevel
+}
+
+// eventKey returns a key identifying the event at the given index of the
+// given run, derived from its content so that replaying the same events
+// yields the same keys.
+func eventKey(run, index int, event fxevent.Event) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%d/%T/%+v", run, index, event, event)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// isInternal reports whether event comes from one of the internal modules or
+// packages hidden by WithHideInternalModules, by its module name or by the
+// package of its constructor, decorator or function.
+func (l *LogrLogger) isInternal(event fxevent.Event) bool {
+	for _, name := range []string{moduleName(event), functionName(event)} {
+		if name == "" {
+			continue
+		}
+		for _, prefix := range l.internalModules {
+			if name == prefix || strings.HasPrefix(name, prefix+"/") || strings.HasPrefix(name, prefix+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moduleName returns the name of the module event was emitted from, if any.
+func moduleName(event fxevent.Event) string {
+	switch e := event.(type) {
+	case *fxevent.Supplied:
+		return e.ModuleName
+	case *fxevent.Provided:
+		return e.ModuleName
+	case *fxevent.Replaced:
+		return e.ModuleName
+	case *fxevent.Decorated:
+		return e.ModuleName
+	case *fxevent.Invoking:
+		return e.ModuleName
+	case *fxevent.Invoked:
+		return e.ModuleName
+	}
+	return ""
+}
+
+// functionName returns the name of the constructor, decorator or function
+// event reports, if any.
+func functionName(event fxevent.Event) string {
+	switch e := event.(type) {
+	case *fxevent.Provided:
+		return e.ConstructorName
+	case *fxevent.Decorated:
+		return e.DecoratorName
+	case *fxevent.Invoking:
+		return e.FunctionName
+	case *fxevent.Invoked:
+		return e.FunctionName
+	}
+	return ""
+}
+
+// isExportedType reports whether typeName looks like an exported type: the
+// leaf name, after any pointer, slice or package qualifier and fx annotation
+// such as [name="x"], starts with an upper case letter.
+func isExportedType(typeName string) bool {
+	for strings.HasPrefix(typeName, "*") || strings.HasPrefix(typeName, "[]") {
+		typeName = strings.TrimPrefix(strings.TrimPrefix(typeName, "*"), "[]")
+	}
+	if i := strings.Index(typeName, "["); i >= 0 {
+		typeName = typeName[:i]
+	}
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	r, _ := utf8.DecodeRuneInString(typeName)
+	return unicode.IsUpper(r)
+}
+
+// Categories of events.
+const (
+	// categoryWiring is the category of the events about building the
+	// dependency graph.
+	categoryWiring = "wiring"
+	// categoryLifecycle is the category of the events about starting and
+	// stopping the application.
+	categoryLifecycle = "lifecycle"
+	// categoryLogger is the category of the events about the logger itself.
+	categoryLogger = "logger"
+)
+
+// eventCategory returns the category of event.
+func eventCategory(event fxevent.Event) string {
+	switch event.(type) {
+	case *fxevent.OnStartExecuting, *fxevent.OnStartExecuted,
+		*fxevent.OnStopExecuting, *fxevent.OnStopExecuted,
+		*fxevent.Started, *fxevent.Stopping, *fxevent.Stopped,
+		*fxevent.RollingBack, *fxevent.RolledBack:
+		return categoryLifecycle
+	case *fxevent.LoggerInitialized:
+		return categoryLogger
+	}
+	return categoryWiring
+}
+
+// sample counts event for sampling, returning the sampling rate that applies
+// to it and its position in the sampled stream, starting at 1.
+func (l *LogrLogger) sample(event fxevent.Event) (rate, n int) {
+	typ := eventType(event)
+	if rate, ok := l.eventSampling[typ]; ok {
+		if l.sampleCounts == nil {
+			l.sampleCounts = make(map[string]int)
+		}
+		l.sampleCounts[typ]++
+		return rate, l.sampleCounts[typ]
+	}
+	if l.sampleRate > 1 {
+		l.sampleN++
+		return l.sampleRate, l.sampleN
+	}
+	return 0, 0
+}
+
+// keepSample reports whether the nth event sampled at rate is logged: the
+// first of every rate events is.
+func keepSample(rate, n int) bool {
+	return rate <= 1 || (n-1)%rate == 0
+}
+
+// takeToken takes a token from the bucket of WithMaxEventsPerSecond, refilled
+// at the configured rate, and reports whether one was available.
+func (l *LogrLogger) takeToken() bool {
+	now := l.now()
+	if l.lastRefill.IsZero() {
+		l.tokens = float64(l.maxPerSecond)
+	} else {
+		l.tokens += now.Sub(l.lastRefill).Seconds() * float64(l.maxPerSecond)
+		if l.tokens > float64(l.maxPerSecond) {
+			l.tokens = float64(l.maxPerSecond)
+		}
+	}
+	l.lastRefill = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// eventType returns the name of the concrete fxevent type of event, such as
+// "Provided".
+func eventType(event fxevent.Event) string {
+	t := reflect.TypeOf(event)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// eventRuntime returns how long the hook reported by event ran, or 0 when
+// event does not report a hook runtime.
+func eventRuntime(event fxevent.Event) time.Duration {
+	switch e := event.(type) {
+	case *fxevent.OnStartExecuted:
+		return e.Runtime
+	case *fxevent.OnStopExecuted:
+		return e.Runtime
+	}
+	return 0
+}
+
+// eventErr returns the error carried by event, if any.
+func eventErr(event fxevent.Event) error {
+	switch e := event.(type) {
+	case *fxevent.OnStartExecuted:
+		return e.Err
+	case *fxevent.OnStopExecuted:
+		return e.Err
+	case *fxevent.Supplied:
+		return e.Err
+	case *fxevent.Provided:
+		return e.Err
+	case *fxevent.Replaced:
+		return e.Err
+	case *fxevent.Decorated:
+		return e.Err
+	case *fxevent.Invoked:
+		return e.Err
+	case *fxevent.Started:
+		return e.Err
+	case *fxevent.Stopped:
+		return e.Err
+	case *fxevent.RollingBack:
+		return e.StartErr
+	case *fxevent.RolledBack:
+		return e.Err
+	case *fxevent.LoggerInitialized:
+		return e.Err
+	}
+	return nil
+}
+
+// now returns the current time according to the configured clock, or the time
+// a held back event was received at while it is logged.
+func (l *LogrLogger) now() time.Time {
+	if !l.emittedAt.IsZero() {
+		return l.emittedAt
+	}
+	if l.clock != nil {
+		return l.clock()
+	}
+	return time.Now()
+}
+
+// message applies the configured message transformations to msg.
+func (l *LogrLogger) message(msg string, isError bool) string {
+	msg = l.messageCase.apply(msg)
+	if l.symbols != nil {
+		msg = l.symbols.symbol(l.eventType, isError) + " " + msg
+	}
+	if l.grepPrefix != "" {
+		msg = l.grepPrefix + " " + msg
+	}
+	return msg
+}
+
+// LogrLogger returns a copy of the logr.Logger events are logged to, with the
+// names and values added by WithName and WithBaseValues, so that other output
+// can be logged through the same configured logger. A LogrLogger without
+// Logger returns a logger discarding everything.
+func (l *LogrLogger) LogrLogger() *logr.Logger {
+	logger := logr.Discard()
+	if l.Logger != nil {
+		logger = *l.Logger
+	}
+	return &logger
+}
+
+// logger returns the logger for the event being logged, named after its
+// category when WithCategoryNames is used and with the values of the context
+// of the event. A LogrLogger without Logger discards events.
+func (l *LogrLogger) logger() logr.Logger {
+	if l.Logger == nil {
+		return logr.Discard()
+	}
+	logger := *l.Logger
+	if l.eventName != "" {
+		logger = logger.WithName(l.eventName)
+	}
+	if len(l.ctxValues) > 0 {
+		logger = logger.WithValues(l.ctxValues...)
+	}
+	return logger
+}
+
+// keyNames returns the keys of the fields of events.
+func (l *LogrLogger) keyNames() KeyNames {
+	if l.keys == nil {
+		return DefaultKeyNames
+	}
+	return *l.keys
+}
+
+// messageSet returns the messages of events.
+func (l *LogrLogger) messageSet() Messages {
+	if l.messages == nil {
+		return DefaultMessages
+	}
+	return *l.messages
+}
+
+// logAside logs a line that is not derived from the event being logged, at
+// the log level and without the annotations of that event.
+func (l *LogrLogger) logAside(msg string, keysAndValues ...interface{}) {
+	level, sampledAt, typ, name, key := l.eventLevel, l.sampledAt, l.eventType, l.eventName, l.eventKey
+	l.eventLevel, l.sampledAt, l.eventType, l.eventName, l.eventKey = l.logLevel, 0, "", "", ""
+	l.logEvent(msg, keysAndValues...)
+	l.eventLevel, l.sampledAt, l.eventType, l.eventName, l.eventKey = level, sampledAt, typ, name, key
+}
+
+// withFields appends the fields enabled by options to keysAndValues.
+func (l *LogrLogger) withFields(keysAndValues []interface{}) []interface{} {
+	if l.timestampKey != "" {
+		keysAndValues = append(keysAndValues, l.timestampKey, l.now().Format(l.timestampLayout))
+	}
+	if l.goVersion {
+		keysAndValues = append(keysAndValues, "go_version", runtime.Version())
+	}
+	if l.version != "" {
+		keysAndValues = append(keysAndValues, "adapter_version", l.version)
+	}
+	if l.sampledAt > 0 {
+		keysAndValues = append(keysAndValues, "sampled", true, "sample_rate", l.sampledAt)
+	}
+	if l.runNumber {
+		keysAndValues = append(keysAndValues, "run_number", l.runs)
+	}
+	if l.startAttempts {
+		keysAndValues = append(keysAndValues, "attempt", l.runs)
+	}
+	if l.eventKey != "" {
+		keysAndValues = append(keysAndValues, "event_key", fmt.Sprintf("%s-%d", l.eventKey, l.eventLine))
+		l.eventLine++
+	}
+	if l.dropped > 0 && l.maxPerSecond > 0 {
+		keysAndValues = append(keysAndValues, "dropped", l.dropped)
+		l.dropped = 0
+	}
+	if l.elapsed {
+		keysAndValues = append(keysAndValues, "elapsed_since_start", l.now().Sub(l.run.start).String())
+	}
+	if l.owners != nil || l.defaultOwner != "" {
+		if owner, ok := l.owners[l.eventModule]; ok {
+			keysAndValues = append(keysAndValues, "owner", owner)
+		} else if l.defaultOwner != "" {
+			keysAndValues = append(keysAndValues, "owner", l.defaultOwner)
+		}
+	}
+	if l.traceExtractor != nil {
+		traceID, spanID := l.traceExtractor()
+		if traceID != "" {
+			keysAndValues = append(keysAndValues, "trace_id", traceID)
+		}
+		if spanID != "" {
+			keysAndValues = append(keysAndValues, "span_id", spanID)
+		}
+	}
+	if l.redactor != nil {
+		keysAndValues = l.redact(keysAndValues)
+	}
+	return keysAndValues
+}
+
+// redact returns a copy of keysAndValues with the string values, and the
+// elements of string slice values, passed through the redactor.
+func (l *LogrLogger) redact(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	copy(redacted, keysAndValues)
+	for i := 0; i+1 < len(redacted); i += 2 {
+		key, ok := redacted[i].(string)
+		if !ok {
+			continue
+		}
+		switch v := redacted[i+1].(type) {
+		case string:
+			redacted[i+1] = l.redactor(key, v)
+		case []string:
+			values := make([]string, len(v))
+			for j, value := range v {
+				values[j] = l.redactor(key, value)
+			}
+			redacted[i+1] = values
+		}
+	}
+	return redacted
+}
+
+// hasKey reports whether key is one of the keys in keysAndValues.
+func hasKey(keysAndValues []interface{}, key string) bool {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// callerStack formats the stack of the calling goroutine, skipping the given
+// number of frames above callerStack itself.
+func callerStack(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteString(":")
+		sb.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
 }
 
 // LogEvent logs an event to the provided Logr logger.
 func (l *LogrLogger) LogEvent(event fxevent.Event) {
+	l.LogEventCtx(context.Background(), event)
+}
+
+// LogEventCtx logs an event like LogEvent, adding the values WithContextValues
+// extracts from ctx to its lines. Events held back by WithDeferUntilStarted
+// are logged with the values of the context of Started.
+func (l *LogrLogger) LogEventCtx(ctx context.Context, event fxevent.Event) {
+	if l.typedSink != nil {
+		l.typedSink(event)
+	}
+	if l.observer != nil {
+		l.observer(event, eventErr(event) != nil)
+	}
+	if l.runtimeObserver != nil {
+		switch e := event.(type) {
+		case *fxevent.OnStartExecuted:
+			l.runtimeObserver(eventType(event), e.FunctionName, e.Runtime)
+		case *fxevent.OnStopExecuted:
+			l.runtimeObserver(eventType(event), e.FunctionName, e.Runtime)
+		}
+	}
+
+	if l.enabled != nil && !l.enabled() && !(l.bypassGate && eventErr(event) != nil) {
+		return
+	}
+	if l.eventFilter != nil && !l.eventFilter(event) {
+		return
+	}
+
+	var ctxValues []interface{}
+	if l.contextValues != nil {
+		ctxValues = l.contextValues(ctx)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ctxValues = ctxValues
+	defer func() { l.ctxValues = nil }()
+
+	if l.deferUntilStarted && !l.started {
+		// fx does not send Started when fx.New fails, so the first error
+		// ends the deferral as well.
+		if _, ok := event.(*fxevent.Started); !ok && eventErr(event) == nil {
+			l.buffered = append(l.buffered, bufferedEvent{event: event, at: l.now()})
+			return
+		}
+		l.started = true
+		for _, b := range l.buffered {
+			l.emittedAt = b.at
+			l.emit(b.event)
+		}
+		l.emittedAt = time.Time{}
+	}
+	l.emit(event)
+}
+
+// bufferedEvent is an event held back by WithDeferUntilStarted, with the time
+// it was received at.
+type bufferedEvent struct {
+	event fxevent.Event
+	at    time.Time
+}
+
+// ReplayBuffered sends the events buffered by WithDeferUntilStarted to target,
+// in the order they were received.
+func (l *LogrLogger) ReplayBuffered(target fxevent.Logger) {
+	l.mu.Lock()
+	events := make([]fxevent.Event, len(l.buffered))
+	for i, b := range l.buffered {
+		events[i] = b.event
+	}
+	l.mu.Unlock()
+
+	for _, e := range events {
+		target.LogEvent(e)
+	}
+}
+
+func (l *LogrLogger) emit(event fxevent.Event) {
+	l.eventType = eventType(event)
+	l.eventModule = moduleName(event)
+	l.eventName = l.categoryNames[eventCategory(event)]
+	if !l.run.active {
+		l.beginRun()
+	}
+	defer l.afterEvent(event)
+	if eventErr(event) != nil {
+		l.run.failed = true
+	}
+	l.run.events++
+	l.eventKey, l.eventLine = "", 0
+	if l.withEventKey {
+		l.eventKey = eventKey(l.runs, l.run.events, event)
+	}
+
+	if l.stopHeartbeat > 0 {
+		l.trackStopHook(event)
+	}
+	if module := moduleName(event); module != "" && l.moduleCount {
+		if l.run.modules == nil {
+			l.run.modules = make(map[string]struct{})
+		}
+		l.run.modules[module] = struct{}{}
+	}
+	if l.timeline {
+		l.run.timeline = append(l.run.timeline, TimelineEntry{
+			EventType: l.eventType,
+			Time:      l.now(),
+			Duration:  eventRuntime(event),
+		})
+	}
+	if e, ok := event.(*fxevent.Provided); ok && l.provideSummary && e.Err == nil {
+		l.recordProvide(e.ModuleName, l.formatName(e.ConstructorName))
+	}
+	if l.optionCount {
+		switch event.(type) {
+		case *fxevent.Supplied, *fxevent.Provided, *fxevent.Replaced, *fxevent.Decorated:
+			l.run.options++
+		default:
+			if !l.run.optionsLogged {
+				l.logAside("options", "count", l.run.options)
+				l.run.optionsLogged = true
+			}
+		}
+	}
+	if _, ok := event.(*fxevent.OnStartExecuting); ok && l.run.hooksStart.IsZero() {
+		l.run.hooksStart = l.now()
+	}
+	if l.startupSummary {
+		switch event.(type) {
+		case *fxevent.Provided:
+			l.run.provided++
+		case *fxevent.Decorated:
+			l.run.decorated++
+		case *fxevent.Invoked:
+			l.run.invoked++
+		}
+	}
+	if l.hookConcurrency {
+		switch event.(type) {
+		case *fxevent.OnStartExecuting, *fxevent.OnStopExecuting:
+			l.run.hooks++
+		case *fxevent.OnStartExecuted, *fxevent.OnStopExecuted:
+			if l.run.hooks > 0 {
+				l.run.hooks--
+			}
+		}
+	}
+	if l.failureTail > 0 {
+		if eventErr(event) != nil && !l.run.tailLogged {
+			l.logFailureTail()
+			l.run.tailLogged = true
+		}
+		// Events logged without any line, such as a successful Invoked,
+		// would take the place of ones worth replaying.
+		if entries := l.decorate(event, l.entries(event)); len(entries) > 0 {
+			l.run.tail = append(l.run.tail, tailEvent{
+				eventType:   l.eventType,
+				eventModule: l.eventModule,
+				eventName:   l.eventName,
+				entries:     entries,
+			})
+			if len(l.run.tail) > l.failureTail {
+				l.run.tail = l.run.tail[1:]
+			}
+		}
+	}
+
+	l.eventLevel = l.levelFor(event)
+	_, started := event.(*fxevent.Started)
+	l.captureStack = l.adapterStack || (l.startFailStack && started)
+	if e, ok := event.(*fxevent.Supplied); ok && l.suppressRepeats && e.Err == nil {
+		key := e.ModuleName + "\x00" + e.TypeName
+		if _, seen := l.supplied[key]; seen {
+			l.run.repeatedSupplies++
+			return
+		}
+		if l.supplied == nil {
+			l.supplied = make(map[string]struct{})
+		}
+		l.supplied[key] = struct{}{}
+	}
+
+	if e, ok := event.(*fxevent.Provided); ok && l.exportedOnly && e.Err == nil {
+		var names []string
+		for _, name := range e.OutputTypeNames {
+			if isExportedType(name) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return
+		}
+		provided := *e
+		provided.OutputTypeNames = names
+		event = &provided
+	}
+
+	if len(l.internalModules) > 0 && eventErr(event) == nil && l.isInternal(event) {
+		return
+	}
+
+	// Skip building the lines of events the logger would drop anyway.
+	if eventErr(event) == nil && !l.logger().V(l.eventLevel).Enabled() {
+		return
+	}
+
+	l.sampledAt = 0
+	if eventErr(event) == nil {
+		if rate, n := l.sample(event); rate > 1 {
+			if !keepSample(rate, n) {
+				return
+			}
+			l.sampledAt = rate
+		}
+	}
+	if l.sampler != nil && eventErr(event) == nil {
+		keep, rate := l.sampler.Sample(event)
+		if !keep {
+			return
+		}
+		if rate > 1 && l.sampledAt > 0 {
+			l.sampledAt *= rate
+		} else if rate > 1 {
+			l.sampledAt = rate
+		}
+	}
+	if l.maxPerSecond > 0 && (l.throttleErrors || eventErr(event) == nil) && !l.takeToken() {
+		l.dropped++
+		return
+	}
+
+	for _, e := range l.decorate(event, l.entries(event)) {
+		if !e.isError {
+			l.logEvent(e.msg, e.keysAndValues...)
+			continue
+		}
+		if l.errorPolicy == nil {
+			l.logError(e.err, e.msg, e.keysAndValues...)
+			continue
+		}
+		useError, level := l.errorPolicy(event)
+		errorLevel, errorAsInfo := l.errorLevel, l.errorAsInfo
+		l.errorLevel, l.errorAsInfo = level, !useError
+		l.logError(e.err, e.msg, e.keysAndValues...)
+		l.errorLevel, l.errorAsInfo = errorLevel, errorAsInfo
+	}
+}
+
+// decorate adds to the entries of event the fields that depend on the events
+// before it, and so are only known when logging it, unlike the fields of
+// entries.
+func (l *LogrLogger) decorate(event fxevent.Event, entries []entry) []entry {
+	if e, ok := event.(*fxevent.Started); ok && e.Err == nil && len(entries) > 0 {
+		var total time.Duration
+		if !l.run.hooksStart.IsZero() {
+			total = l.now().Sub(l.run.hooksStart)
+		}
+		entries[0].keysAndValues = append(entries[0].keysAndValues, l.keyNames().TotalRuntime, total.String())
+	}
+	return entries
+}
+
+// entry is a single log line produced for an event.
+type entry struct {
+	msg           string
+	keysAndValues []interface{}
+	err           error
+	isError       bool
+}
+
+// entries maps event to the log lines it is logged as.
+func (l *LogrLogger) entries(event fxevent.Event) []entry {
+	k, m := l.keyNames(), l.messageSet()
+	var entries []entry
+	info := func(msg string, keysAndValues ...interface{}) {
+		entries = append(entries, entry{msg: msg, keysAndValues: keysAndValues})
+	}
+	fail := func(err error, msg string, keysAndValues ...interface{}) {
+		if l.errorWrapping && err != nil {
+			err = wrapError(event, err)
+		}
+		entries = append(entries, entry{msg: msg, keysAndValues: keysAndValues, err: err, isError: true})
+	}
+
 	switch e := event.(type) {
 	case *fxevent.OnStartExecuting:
-		l.logEvent("OnStart hook executing",
-			"callee", e.FunctionName,
-			"caller", e.CallerName)
+		info(m.OnStartExecuting, k.Callee, e.FunctionName, k.Caller, e.CallerName)
 	case *fxevent.OnStartExecuted:
+		kvs := []interface{}{k.Callee, e.FunctionName, k.Caller, e.CallerName, k.Runtime, l.runtimeValue(e.Runtime)}
 		if e.Err != nil {
-			l.logError(e.Err, "OnStart hook failed",
-				"callee", e.FunctionName,
-				"caller", e.CallerName,
-			)
+			fail(e.Err, m.OnStartFailed, kvs...)
 		} else {
-			l.logEvent("OnStart hook executed",
-				"callee", e.FunctionName,
-				"caller", e.CallerName,
-				"runtime", e.Runtime.String(),
-			)
+			info(m.OnStartExecuted, kvs...)
 		}
 	case *fxevent.OnStopExecuting:
-		l.logEvent("OnStop hook executing",
-			"callee", e.FunctionName,
-			"caller", e.CallerName,
-		)
+		info(m.OnStopExecuting, k.Callee, e.FunctionName, k.Caller, e.CallerName)
 	case *fxevent.OnStopExecuted:
+		kvs := []interface{}{k.Callee, e.FunctionName, k.Caller, e.CallerName, k.Runtime, l.runtimeValue(e.Runtime)}
 		if e.Err != nil {
-			l.logError(e.Err, "OnStop hook failed",
-				"callee", e.FunctionName,
-				"caller", e.CallerName,
-			)
+			fail(e.Err, m.OnStopFailed, kvs...)
 		} else {
-			l.logEvent("OnStop hook executed",
-				"callee", e.FunctionName,
-				"caller", e.CallerName,
-				"runtime", e.Runtime.String(),
-			)
+			info(m.OnStopExecuted, kvs...)
 		}
 	case *fxevent.Supplied:
-		if len(e.ModuleName) != 0 {
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options",
-					"type", e.TypeName,
-					"module", e.ModuleName,
-				)
-			} else {
-				l.logEvent("supplied",
-					"type", e.TypeName,
-					"module", e.ModuleName,
-				)
-			}
+		kvs := withModule([]interface{}{k.Type, l.typeName(e.TypeName)}, k.Module, e.ModuleName)
+		if e.Err != nil {
+			fail(e.Err, m.SupplyFailed, kvs...)
 		} else {
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options",
-					"type", e.TypeName,
-				)
-			} else {
-				l.logEvent("supplied",
-					"type", e.TypeName,
-				)
-			}
+			info(m.Supplied, kvs...)
 		}
 	case *fxevent.Provided:
-		if len(e.ModuleName) != 0 {
-			for _, rtype := range e.OutputTypeNames {
-				if e.Private {
-					l.logEvent("provided",
-						"constructor", e.ConstructorName,
-						"module", e.ModuleName,
-						"type", rtype,
-						"private", true,
-					)
-				} else {
-					l.logEvent("provided",
-						"constructor", e.ConstructorName,
-						"module", e.ModuleName,
-						"type", rtype,
-					)
-				}
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options",
-					"module", e.ModuleName,
-				)
-			}
-		} else {
-			for _, rtype := range e.OutputTypeNames {
-				if e.Private {
-					l.logEvent("provided",
-						"constructor", e.ConstructorName,
-						"type", rtype,
-						"private", true,
-					)
-				} else {
-					l.logEvent("provided",
-						"constructor", e.ConstructorName,
-						"type", rtype,
-					)
-				}
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options")
-			}
+		prefix := withModule([]interface{}{k.Constructor, l.formatName(e.ConstructorName)}, k.Module, e.ModuleName)
+		var suffix []interface{}
+		if e.Private || l.explicitPrivate {
+			suffix = []interface{}{k.Private, e.Private}
+		}
+		for _, kvs := range typeLines(prefix, l.typeFields(e.OutputTypeNames), suffix) {
+			info(m.Provided, kvs...)
+		}
+		if e.Err != nil {
+			fail(e.Err, m.ProvideFailed, withModule(nil, k.Module, e.ModuleName)...)
 		}
 	case *fxevent.Replaced:
-		if len(e.ModuleName) != 0 {
-			for _, rtype := range e.OutputTypeNames {
-				l.logEvent("replaced",
-					"module", e.ModuleName,
-					"type", rtype,
-				)
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while replacing",
-					"module", e.ModuleName,
-				)
-			}
-		} else {
-			for _, rtype := range e.OutputTypeNames {
-				l.logEvent("replaced",
-					"type", rtype,
-				)
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while replacing")
-			}
+		prefix := withModule(nil, k.Module, e.ModuleName)
+		for _, kvs := range typeLines(prefix, l.typeFields(e.OutputTypeNames), nil) {
+			info(m.Replaced, kvs...)
+		}
+		if e.Err != nil {
+			fail(e.Err, m.ReplaceFailed, withModule(nil, k.Module, e.ModuleName)...)
 		}
 	case *fxevent.Decorated:
-		if len(e.ModuleName) != 0 {
-			for _, rtype := range e.OutputTypeNames {
-				l.logEvent("decorated",
-					"decorator", e.DecoratorName,
-					"module", e.ModuleName,
-					"type", rtype,
-				)
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options",
-					"module", e.ModuleName,
-				)
-			}
-		} else {
-			for _, rtype := range e.OutputTypeNames {
-				l.logEvent("decorated",
-					"decorator", e.DecoratorName,
-					"type", rtype,
-				)
-			}
-			if e.Err != nil {
-				l.logError(e.Err, "error encountered while applying options")
-			}
+		prefix := withModule([]interface{}{k.Decorator, l.formatName(e.DecoratorName)}, k.Module, e.ModuleName)
+		for _, kvs := range typeLines(prefix, l.typeFields(e.OutputTypeNames), nil) {
+			info(m.Decorated, kvs...)
+		}
+		if e.Err != nil {
+			fail(e.Err, m.DecorateFailed, withModule(nil, k.Module, e.ModuleName)...)
 		}
 	case *fxevent.Invoking:
 		// Do not log stack as it will make logs hard to read.
-		if len(e.ModuleName) != 0 {
-			l.logEvent("invoking",
-				"function", e.FunctionName,
-				"module", e.ModuleName,
-			)
-		} else {
-			l.logEvent("invoking",
-				"function", e.FunctionName,
-			)
-		}
+		info(m.Invoking, withModule([]interface{}{k.Function, e.FunctionName}, k.Module, e.ModuleName)...)
 	case *fxevent.Invoked:
-		if len(e.ModuleName) != 0 {
-			if e.Err != nil {
-				l.logError(e.Err, "invoke failed",
-					"stack", e.Trace,
-					"function", e.FunctionName,
-					"module", e.ModuleName,
-				)
-			}
-		} else {
-			if e.Err != nil {
-				l.logError(e.Err, "invoke failed",
-					"stack", e.Trace,
-					"function", e.FunctionName,
-				)
+		if e.Err != nil {
+			var kvs []interface{}
+			if !l.noInvokeStack {
+				kvs = append(kvs, k.Stack, e.Trace)
 			}
+			kvs = withModule(append(kvs, k.Function, e.FunctionName), k.Module, e.ModuleName)
+			fail(e.Err, m.InvokeFailed, kvs...)
 		}
 	case *fxevent.Stopping:
-		l.logEvent("received signal",
-			"signal", strings.ToUpper(e.Signal.String()))
+		kvs := []interface{}{k.Signal, strings.ToUpper(e.Signal.String())}
+		if n, ok := signalNumber(e.Signal); ok && l.signalNumber {
+			kvs = append(kvs, k.SignalNumber, n)
+		}
+		info(m.Stopping, kvs...)
 	case *fxevent.Stopped:
 		if e.Err != nil {
-			l.logError(e.Err, "stop failed")
+			fail(e.Err, m.StopFailed)
 		}
 	case *fxevent.RollingBack:
-		l.logError(e.StartErr, "start failed, rolling back")
+		fail(e.StartErr, m.RollingBack)
 	case *fxevent.RolledBack:
 		if e.Err != nil {
-			l.logError(e.Err, "rollback failed")
+			fail(e.Err, m.RollbackFailed)
 		}
 	case *fxevent.Started:
 		if e.Err != nil {
-			l.logError(e.Err, "start failed")
+			fail(e.Err, m.StartFailed)
 		} else {
-			l.logEvent("started")
+			info(m.Started)
 		}
 	case *fxevent.LoggerInitialized:
 		if e.Err != nil {
-			l.logError(e.Err, "custom logger initialization failed")
+			fail(e.Err, m.LoggerInitializationFailed)
 		} else {
-			l.logEvent("initialized custom fxevent.Logger", "function", e.ConstructorName)
+			info(m.LoggerInitialized, k.Function, e.ConstructorName)
+		}
+	default:
+		if !l.dropUnknown {
+			info(m.Unhandled, k.EventType, fmt.Sprintf("%T", event))
+		}
+	}
+
+	if l.hookConcurrency {
+		switch event.(type) {
+		case *fxevent.OnStartExecuting, *fxevent.OnStartExecuted,
+			*fxevent.OnStopExecuting, *fxevent.OnStopExecuted:
+			for i := range entries {
+				entries[i].keysAndValues = append(entries[i].keysAndValues, "concurrent_hooks", l.run.hooks)
+			}
 		}
 	}
+	if l.phaseField {
+		var phase string
+		switch event.(type) {
+		case *fxevent.OnStartExecuting, *fxevent.OnStartExecuted:
+			phase = "start"
+		case *fxevent.OnStopExecuting, *fxevent.OnStopExecuted:
+			phase = "stop"
+		}
+		if phase != "" {
+			for i := range entries {
+				entries[i].keysAndValues = append(entries[i].keysAndValues, k.Phase, phase)
+			}
+		}
+	}
+	if e, ok := event.(*fxevent.Invoked); ok && l.failedConstructor && e.Err != nil {
+		if name := failedConstructor(e.Err, e.Trace); name != "" {
+			last := &entries[len(entries)-1]
+			last.keysAndValues = append(last.keysAndValues, "failed_constructor", name)
+		}
+	}
+	if l.typeDiff != nil {
+		switch e := event.(type) {
+		case *fxevent.Provided:
+			if e.Err == nil {
+				info("output types", l.typeDiffFields(k.Constructor, e.ConstructorName, e.OutputTypeNames)...)
+			}
+		case *fxevent.Decorated:
+			if e.Err == nil {
+				info("output types", l.typeDiffFields(k.Decorator, e.DecoratorName, e.OutputTypeNames)...)
+			}
+		}
+	}
+	return entries
+}
 
+// runtimeValue returns the value of the runtime field for d.
+func (l *LogrLogger) runtimeValue(d time.Duration) interface{} {
+	if l.runtimePrecision > 0 {
+		d = d.Round(l.runtimePrecision)
+	}
+	if l.runtimeUnit > 0 {
+		return float64(d) / float64(l.runtimeUnit)
+	}
+	return d.String()
+}
+
+// withModule appends the module of an event to keysAndValues under key,
+// unless the event is not from a module.
+func withModule(keysAndValues []interface{}, key, module string) []interface{} {
+	if module == "" {
+		return keysAndValues
+	}
+	return append(keysAndValues, key, module)
+}
+
+// typeFields returns the type key/value pairs of each line logged for an event
+// with the given output types.
+func (l *LogrLogger) typeFields(typeNames []string) [][]interface{} {
+	if len(typeNames) == 0 {
+		return nil
+	}
+	if l.withoutTypes {
+		return [][]interface{}{nil}
+	}
+	k := l.keyNames()
+	if l.aggregateTypes {
+		names := make([]string, len(typeNames))
+		for i, typeName := range typeNames {
+			names[i] = l.typeName(typeName)
+		}
+		return [][]interface{}{{k.Types, names}}
+	}
+	fields := make([][]interface{}, len(typeNames))
+	buf := make([]interface{}, 2*len(typeNames))
+	for i, typeName := range typeNames {
+		buf[2*i], buf[2*i+1] = k.Type, l.typeName(typeName)
+		fields[i] = buf[2*i : 2*i+2 : 2*i+2]
+	}
+	return fields
+}
+
+// typeLines returns the key/value pairs of each line logged for an event with
+// the given type fields, between prefix and suffix. The lines share a single
+// allocation; each of them is capped so that appending to one copies it.
+func typeLines(prefix []interface{}, typeFields [][]interface{}, suffix []interface{}) [][]interface{} {
+	size := 0
+	for _, fields := range typeFields {
+		size += len(prefix) + len(fields) + len(suffix)
+	}
+	buf := make([]interface{}, 0, size)
+	lines := make([][]interface{}, len(typeFields))
+	for i, fields := range typeFields {
+		start := len(buf)
+		buf = append(buf, prefix...)
+		buf = append(buf, fields...)
+		buf = append(buf, suffix...)
+		lines[i] = buf[start:len(buf):len(buf)]
+	}
+	return lines
+}
+
+// typeDiffFields returns the fields comparing the output types of a
+// constructor or decorator with the types expected by WithTypeDiff.
+func (l *LogrLogger) typeDiffFields(key, name string, typeNames []string) []interface{} {
+	fields := []interface{}{key, l.formatName(name), "output_count", len(typeNames)}
+	expected, ok := l.typeDiff(name)
+	if !ok {
+		return fields
+	}
+	return append(fields,
+		"expected_count", len(expected),
+		"missing", missingTypes(expected, typeNames),
+		"unexpected", missingTypes(typeNames, expected),
+	)
+}
+
+// missingTypes returns the types of want that are not in got.
+func missingTypes(want, got []string) []string {
+	missing := []string{}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if g == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
+
+// typeName returns the name logged for a type.
+func (l *LogrLogger) typeName(name string) string {
+	if l.fqTypeNames != nil {
+		name = l.fqTypeNames(name)
+	}
+	return l.formatName(name)
+}
+
+// formatName returns the name of a type, constructor or decorator as logged.
+func (l *LogrLogger) formatName(name string) string {
+	if l.typeFormatter != nil {
+		return l.typeFormatter(name)
+	}
+	return name
+}
+
+// beginRun starts a new run.
+func (l *LogrLogger) beginRun() {
+	l.run = runState{active: true, start: l.now()}
+	l.runs++
+
+	// Announce once the first run has begun, so that the line gets the run
+	// fields of that run.
+	if l.announceInit && !l.announced {
+		l.logAside("fx-logr initialized")
+		l.announced = true
+	}
+
+	if l.configSnapshot {
+		c := l.config()
+		l.logAside("config",
+			"log_level", c.LogLevel,
+			"error_level", c.ErrorLevel,
+			"features", c.Features,
+		)
+	}
+}
+
+// recordError adds err to the error group of its fingerprint.
+func (l *LogrLogger) recordError(err error) {
+	fingerprint := errorFingerprint(err)
+	for i := range l.run.errorGroups {
+		if l.run.errorGroups[i].fingerprint == fingerprint {
+			l.run.errorGroups[i].count++
+			return
+		}
+	}
+	l.run.errorGroups = append(l.run.errorGroups, errorGroup{
+		fingerprint: fingerprint,
+		count:       1,
+		sample:      err.Error(),
+	})
+}
+
+// logErrorDigest logs a line for each error group of the run, up to
+// errorDigestLimit, followed by the number of groups left out if any.
+func (l *LogrLogger) logErrorDigest() {
+	for i, g := range l.run.errorGroups {
+		if i == errorDigestLimit {
+			l.logAside("error digest truncated", "omitted_groups", len(l.run.errorGroups)-i)
+			break
+		}
+		l.logAside("error digest",
+			"fingerprint", g.fingerprint,
+			"count", g.count,
+			"sample", g.sample,
+		)
+	}
+}
+
+// tailEvent is an event kept by WithFailureTail, with the lines it was logged
+// as and what they are annotated with.
+type tailEvent struct {
+	eventType   string
+	eventModule string
+	eventName   string
+	entries     []entry
+}
+
+// logFailureTail logs the events kept by WithFailureTail at the error level,
+// oldest first, each annotated as when it was received.
+func (l *LogrLogger) logFailureTail() {
+	level, sampledAt, typ, module, name, key := l.eventLevel, l.sampledAt, l.eventType, l.eventModule, l.eventName, l.eventKey
+	l.eventLevel, l.sampledAt = l.errorLevel, 0
+	// The replayed lines are not lines of the event being logged.
+	l.eventKey = ""
+	for _, t := range l.run.tail {
+		l.eventType, l.eventModule, l.eventName = t.eventType, t.eventModule, t.eventName
+		for _, e := range t.entries {
+			l.logEvent(e.msg, append(e.keysAndValues, "failure_tail", true)...)
+		}
+	}
+	l.eventLevel, l.sampledAt, l.eventType, l.eventModule, l.eventName, l.eventKey = level, sampledAt, typ, module, name, key
+}
+
+// moduleProvides is the summary of the constructors provided by a module.
+type moduleProvides struct {
+	module       string
+	count        int
+	constructors []string
+}
+
+// provideSummaryLimit caps the number of constructors listed per module by
+// WithModuleProvideSummary.
+const provideSummaryLimit = 10
+
+// recordProvide records that module provided constructor.
+func (l *LogrLogger) recordProvide(module, constructor string) {
+	for i := range l.run.provides {
+		p := &l.run.provides[i]
+		if p.module == module {
+			p.count++
+			if len(p.constructors) < provideSummaryLimit {
+				p.constructors = append(p.constructors, constructor)
+			}
+			return
+		}
+	}
+	l.run.provides = append(l.run.provides, moduleProvides{
+		module:       module,
+		count:        1,
+		constructors: []string{constructor},
+	})
+}
+
+// afterEvent logs the events due once event has been logged, and ends the
+// current run when event is the last event of a run.
+func (l *LogrLogger) afterEvent(event fxevent.Event) {
+	switch e := event.(type) {
+	case *fxevent.Stopped:
+		if l.errorSummary {
+			l.logAside("errors summary",
+				"count", l.run.errorCount,
+				"errors", l.run.errors,
+			)
+		}
+		if l.errorDigest {
+			l.logErrorDigest()
+		}
+		if l.cleanShutdownFlag {
+			l.logAside("shutdown", "clean", !l.run.failed)
+		}
+		l.run.active = false
+	case *fxevent.Started:
+		if l.run.repeatedSupplies > 0 {
+			l.logAside("suppressed repeated supplies", "count", l.run.repeatedSupplies)
+		}
+		if l.provideSummary {
+			for _, p := range l.run.provides {
+				kvs := []interface{}{}
+				if p.module != "" {
+					kvs = append(kvs, l.keyNames().Module, p.module)
+				}
+				l.logAside("module provides", append(kvs,
+					"count", p.count,
+					"constructors", p.constructors,
+				)...)
+			}
+		}
+		if l.moduleCount {
+			l.logAside("modules loaded", "module_count", len(l.run.modules))
+		}
+		if l.startupSummary && e.Err == nil {
+			l.logAside("fx startup summary",
+				"provided", l.run.provided,
+				"decorated", l.run.decorated,
+				"invoked", l.run.invoked,
+				"startup_time", l.now().Sub(l.run.start).String(),
+			)
+		}
+		if l.startupBudget > 0 {
+			if startup := l.now().Sub(l.run.start); startup > l.startupBudget {
+				l.logAside("startup exceeded budget",
+					"startup_time", startup.String(),
+					"budget", l.startupBudget.String(),
+					"overage", (startup - l.startupBudget).String(),
+				)
+			}
+		}
+		if e.Err != nil {
+			l.run.active = false
+		}
+	}
+}
+
+// writeError passes err, if not nil, to the handler set by
+// WithWriteErrorHandler.
+func (l *LogrLogger) writeError(err error) {
+	if err != nil && l.writeErrorHandler != nil {
+		l.writeErrorHandler(err)
+	}
+}
+
+// New returns a LogrLogger backed by l and configured with opts. When l is
+// nil, events are discarded.
+func New(l *logr.Logger, opts ...Option) *LogrLogger {
+	if l == nil {
+		discard := logr.Discard()
+		l = &discard
+	}
+	logger := &LogrLogger{Logger: l}
+	for _, opt := range opts {
+		opt(logger)
+	}
+	return logger
+}
+
+// NewNop returns a fxevent.Logger that logs nothing, for tests of code taking
+// a fxevent.Logger.
+func NewNop() fxevent.Logger {
+	l := logr.Discard()
+	return New(&l)
 }
 
 // WithLogr returns a function that returns a fxevent.Logger backed by a logr.Logger.
-func WithLogr(l *logr.Logger) func() fxevent.Logger {
+func WithLogr(l *logr.Logger, opts ...Option) func() fxevent.Logger {
 	return func() fxevent.Logger {
-		return &LogrLogger{Logger: l}
+		return New(l, opts...)
 	}
 }
+
+// WithLogSink returns a function that returns a fxevent.Logger backed by a
+// logr.Logger wrapping sink, like WithLogr(&logr.New(sink), opts...).
+func WithLogSink(sink logr.LogSink, opts ...Option) func() fxevent.Logger {
+	l := logr.New(sink)
+	return WithLogr(&l, opts...)
+}
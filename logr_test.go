@@ -15,14 +15,24 @@
 package fxlogr
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/funcr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 )
 
@@ -57,8 +67,9 @@ func TestLogrLogger(t *testing.T) {
 				FunctionName: "hook.onStart1",
 				CallerName:   "bytes.NewBuffer",
 				Err:          fmt.Errorf("some error"),
+				Runtime:      time.Millisecond * 3,
 			},
-			wantMessage: "\"msg\"=\"OnStop hook failed\" \"error\"=\"some error\" \"callee\"=\"hook.onStart1\" \"caller\"=\"bytes.NewBuffer\"",
+			wantMessage: "\"msg\"=\"OnStop hook failed\" \"error\"=\"some error\" \"callee\"=\"hook.onStart1\" \"caller\"=\"bytes.NewBuffer\" \"runtime\"=\"3ms\"",
 		},
 		{
 			name: "OnStopExecuted",
@@ -76,8 +87,9 @@ func TestLogrLogger(t *testing.T) {
 				FunctionName: "hook.onStart1",
 				CallerName:   "bytes.NewBuffer",
 				Err:          fmt.Errorf("some error"),
+				Runtime:      time.Millisecond * 3,
 			},
-			wantMessage: "\"msg\"=\"OnStart hook failed\" \"error\"=\"some error\" \"callee\"=\"hook.onStart1\" \"caller\"=\"bytes.NewBuffer\"",
+			wantMessage: "\"msg\"=\"OnStart hook failed\" \"error\"=\"some error\" \"callee\"=\"hook.onStart1\" \"caller\"=\"bytes.NewBuffer\" \"runtime\"=\"3ms\"",
 		},
 		{
 			name: "OnStartExecuted",
@@ -188,7 +200,7 @@ func TestLogrLogger(t *testing.T) {
 		{
 			name:        "Started",
 			give:        &fxevent.Started{},
-			wantMessage: "\"level\"=0 \"msg\"=\"started\"",
+			wantMessage: "\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
 		},
 		{
 			name:        "LoggerInitialized/Error",
@@ -223,3 +235,1759 @@ func TestLogrLogger(t *testing.T) {
 		})
 	}
 }
+
+// newCapturingLogr returns a logr.Logger that records every formatted line.
+func newCapturingLogr() (*logr.Logger, *[]string) {
+	var lines []string
+	l := funcr.New(
+		func(_, args string) {
+			lines = append(lines, args)
+		},
+		funcr.Options{},
+	)
+	return &l, &lines
+}
+
+func TestWithGoVersion(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithGoVersion())()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	goVersion := fmt.Sprintf("\"go_version\"=%q", runtime.Version())
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" " + goVersion,
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\" " + goVersion,
+	}, *lines)
+}
+
+type eventRecorder struct {
+	events []fxevent.Event
+}
+
+func (r *eventRecorder) LogEvent(event fxevent.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestWithDeferUntilStarted(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithDeferUntilStarted())().(*LogrLogger)
+
+	supplied := &fxevent.Supplied{TypeName: "*bytes.Buffer"}
+	invoking := &fxevent.Invoking{FunctionName: "bytes.NewBuffer()"}
+	logger.LogEvent(supplied)
+	logger.LogEvent(invoking)
+	assert.Empty(t, *lines)
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopping{Signal: os.Interrupt})
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"*bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"bytes.NewBuffer()\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"received signal\" \"signal\"=\"INTERRUPT\"",
+	}, *lines)
+
+	recorder := &eventRecorder{}
+	logger.ReplayBuffered(recorder)
+	assert.Equal(t, []fxevent.Event{supplied, invoking}, recorder.events)
+}
+
+func TestWithDeferUntilStartedTimes(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithDeferUntilStarted(), WithClock(clock.Now),
+		WithElapsedSinceStart(), WithStartupBudget(time.Second))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	clock.Add(time.Second)
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	clock.Add(5 * time.Second)
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="invoking" "function"="main.run()" "elapsed_since_start"="0s"`,
+		`"level"=0 "msg"="OnStart hook executing" "callee"="a()" "caller"="main()" "elapsed_since_start"="1s"`,
+		`"level"=0 "msg"="started" "total_runtime"="5s" "elapsed_since_start"="6s"`,
+		`"level"=0 "msg"="startup exceeded budget" "startup_time"="6s" "budget"="1s" "overage"="5s" "elapsed_since_start"="6s"`,
+	}, *lines)
+}
+
+func TestWithDeferUntilStartedFailedNew(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	app := fx.New(
+		fx.WithLogger(WithLogr(&l, WithDeferUntilStarted())),
+		fx.Invoke(func(*bytes.Buffer) {}),
+	)
+	require.Error(t, app.Err())
+
+	var failed []string
+	for _, record := range recorder.Records() {
+		if record.IsError {
+			failed = append(failed, record.Msg)
+		}
+	}
+	assert.Contains(t, failed, "invoke failed")
+}
+
+func TestWithAdapterStack(t *testing.T) {
+	var kvs [][]interface{}
+	sink := funcr.New(func(_, _ string) {}, funcr.Options{})
+	l := logr.New(&kvRecordingSink{LogSink: sink.GetSink(), kvs: &kvs})
+	logger := WithLogr(&l, WithAdapterStack())()
+
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "bytes.NewBuffer()", Err: errors.New("some error"), Trace: "fx trace"})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Len(t, kvs, 3)
+	assert.Equal(t, "adapter_stack", kvs[0][0])
+	assert.True(t, strings.Contains(kvs[0][1].(string), "TestWithAdapterStack"))
+	assert.NotContains(t, kvs[1], "adapter_stack")
+	assert.Equal(t, []interface{}{"total_runtime", "0s"}, kvs[2])
+}
+
+func TestWithStartFailureStack(t *testing.T) {
+	var kvs [][]interface{}
+	sink := funcr.New(func(_, _ string) {}, funcr.Options{})
+	l := logr.New(&kvRecordingSink{LogSink: sink.GetSink(), kvs: &kvs})
+	logger := WithLogr(&l, WithStartFailureStack())()
+
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+
+	assert.Len(t, kvs, 2)
+	assert.Empty(t, kvs[0])
+	assert.Equal(t, "adapter_stack", kvs[1][0])
+	assert.Contains(t, kvs[1][1], "TestWithStartFailureStack")
+}
+
+// kvRecordingSink records the key/value pairs of every log call.
+type kvRecordingSink struct {
+	logr.LogSink
+	kvs *[][]interface{}
+}
+
+func (s *kvRecordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	*s.kvs = append(*s.kvs, keysAndValues)
+}
+
+func (s *kvRecordingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	*s.kvs = append(*s.kvs, keysAndValues)
+}
+
+func TestWithErrorSummary(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorSummary())()
+
+	logger.LogEvent(&fxevent.Provided{Err: errors.New("provide error")})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "bytes.NewBuffer()", Err: errors.New("invoke error")})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("stop error")})
+	assert.Equal(t, "\"level\"=0 \"msg\"=\"errors summary\" \"count\"=3 \"errors\"=[\"provide error\",\"invoke error\",\"stop error\"]", (*lines)[len(*lines)-1])
+
+	// The next run starts from scratch.
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{})
+	assert.Equal(t, "\"level\"=0 \"msg\"=\"errors summary\" \"count\"=0 \"errors\"=[]", (*lines)[len(*lines)-1])
+}
+
+func TestWithErrorSummaryLimit(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithErrorSummary())()
+
+	for i := 0; i < errorSummaryLimit+5; i++ {
+		logger.LogEvent(&fxevent.Provided{Err: fmt.Errorf("error %d", i)})
+	}
+	logger.LogEvent(&fxevent.Stopped{})
+
+	last := (*lines)[len(*lines)-1]
+	assert.Contains(t, last, fmt.Sprintf("\"count\"=%d", errorSummaryLimit+5))
+	assert.Contains(t, last, fmt.Sprintf("\"error %d\"", errorSummaryLimit-1))
+	assert.NotContains(t, last, fmt.Sprintf("\"error %d\"", errorSummaryLimit))
+}
+
+func TestWithMessageCase(t *testing.T) {
+	tests := []struct {
+		give        MessageCase
+		wantMessage string
+	}{
+		{give: MessageCaseNone, wantMessage: "OnStart hook executing"},
+		{give: MessageCaseLower, wantMessage: "onstart hook executing"},
+		{give: MessageCaseUpper, wantMessage: "ONSTART HOOK EXECUTING"},
+		{give: MessageCaseTitle, wantMessage: "OnStart Hook Executing"},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		WithLogr(l, WithMessageCase(tt.give))().LogEvent(&fxevent.OnStartExecuting{
+			FunctionName: "hook.onStart",
+			CallerName:   "bytes.NewBuffer",
+		})
+
+		assert.Equal(t, []string{
+			"\"level\"=0 \"msg\"=\"" + tt.wantMessage + "\" \"callee\"=\"hook.onStart\" \"caller\"=\"bytes.NewBuffer\"",
+		}, *lines)
+	}
+}
+
+func TestWithRunNumber(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithRunNumber())()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopping{Signal: os.Interrupt})
+	logger.LogEvent(&fxevent.Stopped{})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"run_number\"=1",
+		"\"level\"=0 \"msg\"=\"received signal\" \"signal\"=\"INTERRUPT\" \"run_number\"=1",
+		"\"msg\"=\"start failed\" \"error\"=\"some error\" \"run_number\"=2",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"run_number\"=3",
+	}, *lines)
+}
+
+func TestWithModulePattern(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithModulePattern("internal/*", 2))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "db.New()", ModuleName: "internal/db"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "db.Migrate()", ModuleName: "internal/db/migrations"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "http.New()", ModuleName: "http"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	// funcr only logs up to V(0), so events raised to V(2) are dropped.
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"db.Migrate()\" \"module\"=\"internal/db/migrations\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"http.New()\" \"module\"=\"http\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\"",
+	}, *lines)
+}
+
+func TestWithSampling(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSampling(2))()
+
+	for _, name := range []string{"a()", "b()", "c()"} {
+		logger.LogEvent(&fxevent.Invoking{FunctionName: name})
+	}
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "d()", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"a()\" \"sampled\"=true \"sample_rate\"=2",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"c()\" \"sampled\"=true \"sample_rate\"=2",
+		"\"msg\"=\"invoke failed\" \"error\"=\"some error\" \"stack\"=\"\" \"function\"=\"d()\"",
+	}, *lines)
+}
+
+func TestWithEventSampling(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithEventSampling(map[string]int{
+		"Provided":  4,
+		"Decorated": 2,
+	}))()
+
+	for i := 0; i < 8; i++ {
+		logger.LogEvent(&fxevent.Provided{ConstructorName: fmt.Sprintf("new%d()", i), OutputTypeNames: []string{"int"}})
+		logger.LogEvent(&fxevent.Decorated{DecoratorName: fmt.Sprintf("decorate%d()", i), OutputTypeNames: []string{"int"}})
+	}
+	logger.LogEvent(&fxevent.Provided{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"new0()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=4",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate0()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=2",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate2()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=2",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"new4()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=4",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate4()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=2",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate6()\" \"type\"=\"int\" \"sampled\"=true \"sample_rate\"=2",
+		"\"msg\"=\"error encountered while applying options\" \"error\"=\"some error\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\"",
+	}, *lines)
+}
+
+func TestWithEnableFunc(t *testing.T) {
+	enabled := false
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithEnableFunc(func() bool { return enabled }))()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	assert.Empty(t, *lines)
+
+	enabled = true
+	logger.LogEvent(&fxevent.Started{})
+	assert.Equal(t, []string{"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\""}, *lines)
+}
+
+func TestWithErrorsBypassEnableFunc(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l,
+		WithEnableFunc(func() bool { return false }),
+		WithErrorsBypassEnableFunc(),
+	)()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{"\"msg\"=\"stop failed\" \"error\"=\"some error\""}, *lines)
+}
+
+func TestWithTraceExtractor(t *testing.T) {
+	spanID := "00f067aa0ba902b7"
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithTraceExtractor(func() (string, string) {
+		return "4bf92f3577b34da6a3ce929d0e0e4736", spanID
+	}))()
+
+	logger.LogEvent(&fxevent.Started{})
+	spanID = ""
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"trace_id\"=\"4bf92f3577b34da6a3ce929d0e0e4736\" \"span_id\"=\"00f067aa0ba902b7\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\" \"trace_id\"=\"4bf92f3577b34da6a3ce929d0e0e4736\"",
+	}, *lines)
+}
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestWithElapsedSinceStart(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithElapsedSinceStart(), WithClock(clock.Now))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	clock.Add(5 * time.Millisecond)
+	logger.LogEvent(&fxevent.Started{})
+	clock.Add(time.Second)
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	clock.Add(time.Minute)
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\" \"elapsed_since_start\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"elapsed_since_start\"=\"5ms\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\" \"elapsed_since_start\"=\"1.005s\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"elapsed_since_start\"=\"0s\"",
+	}, *lines)
+}
+
+func TestWithTypedSink(t *testing.T) {
+	recorder := &eventRecorder{}
+	l := logr.Discard()
+	logger := WithLogr(&l, WithTypedSink(recorder.LogEvent), WithEnableFunc(func() bool { return false }))()
+
+	events := []fxevent.Event{
+		&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}},
+		&fxevent.Started{Err: errors.New("some error")},
+	}
+	for _, e := range events {
+		logger.LogEvent(e)
+	}
+
+	assert.Equal(t, events, recorder.events)
+	assert.Same(t, events[0], recorder.events[0])
+}
+
+func TestWithStartupBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		giveDelay time.Duration
+		want      []string
+	}{
+		{
+			name:      "Under",
+			giveDelay: 900 * time.Millisecond,
+			want: []string{
+				"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\"",
+				"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+			},
+		},
+		{
+			name:      "Over",
+			giveDelay: 1500 * time.Millisecond,
+			want: []string{
+				"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\"",
+				"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+				"\"level\"=0 \"msg\"=\"startup exceeded budget\" \"startup_time\"=\"1.5s\" \"budget\"=\"1s\" \"overage\"=\"500ms\"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithStartupBudget(time.Second), WithClock(clock.Now))()
+
+		logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+		clock.Add(tt.giveDelay)
+		logger.LogEvent(&fxevent.Started{})
+
+		assert.Equal(t, tt.want, *lines, tt.name)
+	}
+}
+
+func TestWithModuleCount(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithModuleCount())()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "db.New()", ModuleName: "db"})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "db.Wrap()", ModuleName: "db"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "http.Serve()", ModuleName: "http"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Started{})
+	assert.Equal(t, "\"level\"=0 \"msg\"=\"modules loaded\" \"module_count\"=2", (*lines)[len(*lines)-1])
+
+	logger.LogEvent(&fxevent.Stopped{})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int", ModuleName: "config"})
+	logger.LogEvent(&fxevent.Started{})
+	assert.Equal(t, "\"level\"=0 \"msg\"=\"modules loaded\" \"module_count\"=1", (*lines)[len(*lines)-1])
+}
+
+func TestWithFailureTail(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithFailureTail(2), WithSampling(100))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "a()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "a()"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "b()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "b()"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "c()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "c()", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"a()\" \"sampled\"=true \"sample_rate\"=100",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"b()\" \"failure_tail\"=true",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"c()\" \"failure_tail\"=true",
+		"\"msg\"=\"invoke failed\" \"error\"=\"some error\" \"stack\"=\"\" \"function\"=\"c()\"",
+		"\"msg\"=\"start failed\" \"error\"=\"some error\"",
+	}, *lines)
+}
+
+func TestWithFailureTailAnnotations(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithFailureTail(2), WithSymbols(), WithHookConcurrency())()
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main.run()"})
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "b()", CallerName: "main.run()"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main.run()", Err: errors.New("some error")})
+
+	// The replayed lines keep the symbol and fields of their own events, not
+	// those of the failed hook.
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"→ OnStart hook executing\" \"callee\"=\"a()\" \"caller\"=\"main.run()\" \"concurrent_hooks\"=1 \"failure_tail\"=true",
+		"\"level\"=0 \"msg\"=\"→ OnStart hook executing\" \"callee\"=\"b()\" \"caller\"=\"main.run()\" \"concurrent_hooks\"=2 \"failure_tail\"=true",
+	}, (*lines)[2:4])
+}
+
+func TestWithDeterministic(t *testing.T) {
+	run := func() []string {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithDeterministic(), WithElapsedSinceStart(), WithRunNumber())()
+
+		logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+		time.Sleep(time.Millisecond)
+		logger.LogEvent(&fxevent.Started{})
+		return *lines
+	}
+
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Equal(t, "\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"run_number\"=1 \"elapsed_since_start\"=\"0s\"", first[1])
+}
+
+func TestWithFQTypeNames(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithFQTypeNames(func(name string) string {
+		return strings.Replace(name, "bytes.", "bytes:bytes.", 1)
+	}))()
+
+	logger.LogEvent(&fxevent.Supplied{TypeName: "*bytes.Buffer"})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer", "io.Writer"}})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"*bytes:bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"*bytes:bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"io.Writer\"",
+	}, *lines)
+}
+
+func TestWithSeverityTierMap(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSeverityTierMap(map[string]int{
+		"Provided": 1,
+		"Started":  3,
+	}))()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"*bytes.Buffer\" \"sev\"=1",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"main.run()\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"sev\"=3",
+		"\"msg\"=\"start failed\" \"error\"=\"some error\" \"sev\"=8",
+	}, *lines)
+
+	l, lines = newCapturingLogr()
+	WithLogr(l, WithSeverityTierMap(map[string]int{"error": 10}))().LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	assert.Equal(t, []string{"\"msg\"=\"stop failed\" \"error\"=\"some error\" \"sev\"=10"}, *lines)
+}
+
+func TestWithMaxEventsPerSecond(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithMaxEventsPerSecond(2), WithClock(clock.Now))()
+
+	for _, name := range []string{"a()", "b()", "c()", "d()"} {
+		logger.LogEvent(&fxevent.Invoking{FunctionName: name})
+	}
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "d()", Err: errors.New("some error")})
+	clock.Add(500 * time.Millisecond)
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "e()"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "f()"})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"a()\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"b()\"",
+		"\"msg\"=\"invoke failed\" \"error\"=\"some error\" \"stack\"=\"\" \"function\"=\"d()\" \"dropped\"=2",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"e()\"",
+	}, *lines)
+}
+
+func TestWithThrottleErrors(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithMaxEventsPerSecond(1), WithThrottleErrors(), WithClock(clock.Now))()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+	clock.Add(time.Second)
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"dropped\"=1",
+	}, *lines)
+}
+
+func TestWithTypeDiff(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithTypeDiff(func(name string) ([]string, bool) {
+		if name == "bytes.NewBuffer()" {
+			return []string{"*bytes.Buffer", "io.Reader"}, true
+		}
+		return nil, false
+	}))()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer", "io.Writer"}})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "decorate()", OutputTypeNames: []string{"*bytes.Buffer"}})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"*bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"io.Writer\"",
+		"\"level\"=0 \"msg\"=\"output types\" \"constructor\"=\"bytes.NewBuffer()\" \"output_count\"=2 \"expected_count\"=2 \"missing\"=[\"io.Reader\"] \"unexpected\"=[\"io.Writer\"]",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate()\" \"type\"=\"*bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"output types\" \"decorator\"=\"decorate()\" \"output_count\"=1",
+	}, *lines)
+}
+
+func TestWithoutTypeDetails(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithoutTypeDetails())()
+
+	types := []string{"*bytes.Buffer", "io.Writer"}
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", ModuleName: "myModule", OutputTypeNames: types, Private: true})
+	logger.LogEvent(&fxevent.Replaced{ModuleName: "myModule", OutputTypeNames: types})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "decorate()", OutputTypeNames: types})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"module\"=\"myModule\" \"private\"=true",
+		"\"level\"=0 \"msg\"=\"replaced\" \"module\"=\"myModule\"",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate()\"",
+	}, *lines)
+}
+
+func TestWithTimestamp(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithTimestamp("ts", time.RFC3339Nano), WithClock(clock.Now))()
+
+	logger.LogEvent(&fxevent.Started{})
+	clock.Add(1500 * time.Millisecond)
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"ts\"=\"2023-01-01T00:00:00Z\"",
+		"\"msg\"=\"stop failed\" \"error\"=\"some error\" \"ts\"=\"2023-01-01T00:00:01.5Z\"",
+	}, *lines)
+}
+
+func TestWithOptionCount(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithOptionCount(), WithModulePattern("*", 1))()
+
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int"})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer", "io.Writer"}})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "decorate()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()", ModuleName: "main"})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"int\"",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"*bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"provided\" \"constructor\"=\"bytes.NewBuffer()\" \"type\"=\"io.Writer\"",
+		"\"level\"=0 \"msg\"=\"decorated\" \"decorator\"=\"decorate()\" \"type\"=\"*bytes.Buffer\"",
+		"\"level\"=0 \"msg\"=\"options\" \"count\"=3",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+	}, *lines)
+}
+
+func TestWithAnnounceInit(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithAnnounceInit())()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"fx-logr initialized\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+	}, *lines)
+}
+
+func TestWithAnnounceInitRunFields(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithAnnounceInit(), WithClock(clock.Now), WithElapsedSinceStart(), WithRunNumber())()
+
+	clock.Add(time.Second)
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="fx-logr initialized" "run_number"=1 "elapsed_since_start"="0s"`,
+		`"level"=0 "msg"="invoking" "function"="main.run()" "run_number"=1 "elapsed_since_start"="0s"`,
+	}, *lines)
+}
+
+func TestWithOwnershipMap(t *testing.T) {
+	owners := map[string]string{"db": "storage-team"}
+
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithOwnershipMap(owners))()
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "db.New()", ModuleName: "db"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "http.New()", ModuleName: "http"})
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"db.New()\" \"module\"=\"db\" \"owner\"=\"storage-team\"",
+		"\"level\"=0 \"msg\"=\"invoking\" \"function\"=\"http.New()\" \"module\"=\"http\"",
+	}, *lines)
+
+	l, lines = newCapturingLogr()
+	logger = WithLogr(l, WithOwnershipMap(owners), WithDefaultOwner("platform-team"))()
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "db.New()", ModuleName: "db", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+	assert.Equal(t, []string{
+		"\"msg\"=\"invoke failed\" \"error\"=\"some error\" \"stack\"=\"\" \"function\"=\"db.New()\" \"module\"=\"db\" \"owner\"=\"storage-team\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"owner\"=\"platform-team\"",
+	}, *lines)
+}
+
+func TestWithModuleProvideSummary(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithModuleProvideSummary(), WithoutTypeDetails())()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "db.New()", ModuleName: "db", OutputTypeNames: []string{"*sql.DB"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "http.New()", ModuleName: "http", OutputTypeNames: []string{"*http.Server"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "db.NewRepo()", ModuleName: "db", OutputTypeNames: []string{"*db.Repo"}})
+	for i := 0; i < provideSummaryLimit; i++ {
+		logger.LogEvent(&fxevent.Provided{ConstructorName: fmt.Sprintf("new%d()", i), ModuleName: "http", OutputTypeNames: []string{"int"}})
+	}
+	*lines = nil
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"module provides\" \"module\"=\"db\" \"count\"=2 \"constructors\"=[\"db.New()\",\"db.NewRepo()\"]",
+		"\"level\"=0 \"msg\"=\"module provides\" \"module\"=\"http\" \"count\"=11 \"constructors\"=[\"http.New()\",\"new0()\",\"new1()\",\"new2()\",\"new3()\",\"new4()\",\"new5()\",\"new6()\",\"new7()\",\"new8()\"]",
+	}, *lines)
+}
+
+func TestWithSymbols(t *testing.T) {
+	tests := []struct {
+		name        string
+		give        fxevent.Event
+		wantMessage string
+		wantASCII   string
+	}{
+		{
+			name:        "Progress",
+			give:        &fxevent.Invoking{FunctionName: "main.run()"},
+			wantMessage: "→ invoking",
+			wantASCII:   "> invoking",
+		},
+		{
+			name:        "Success",
+			give:        &fxevent.Started{},
+			wantMessage: "✓ started",
+			wantASCII:   "+ started",
+		},
+		{
+			name:        "Failure",
+			give:        &fxevent.Started{Err: errors.New("some error")},
+			wantMessage: "✗ start failed",
+			wantASCII:   "x start failed",
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		WithLogr(l, WithSymbols())().LogEvent(tt.give)
+		assert.Contains(t, (*lines)[0], "\"msg\"=\""+tt.wantMessage+"\"", tt.name)
+
+		l, lines = newCapturingLogr()
+		WithLogr(l, WithSymbolSet(ASCIISymbols))().LogEvent(tt.give)
+		assert.Contains(t, (*lines)[0], "\"msg\"=\""+tt.wantASCII+"\"", tt.name)
+	}
+}
+
+func TestWithSuppressRepeatSupplies(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSuppressRepeatSupplies())()
+
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int"})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "string"})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{})
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"int\"",
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"string\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+	}, *lines)
+
+	*lines = nil
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int"})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "string"})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int", ModuleName: "myModule"})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+	assert.Equal(t, []string{
+		"\"level\"=0 \"msg\"=\"supplied\" \"type\"=\"int\" \"module\"=\"myModule\"",
+		"\"msg\"=\"error encountered while applying options\" \"error\"=\"some error\" \"type\"=\"int\"",
+		"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\"",
+		"\"level\"=0 \"msg\"=\"suppressed repeated supplies\" \"count\"=2",
+	}, *lines)
+}
+
+func TestWithGrepPrefix(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithGrepPrefix("FXLOG"), WithSymbols())()
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="FXLOG ✓ provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer"`,
+		`"msg"="FXLOG ✗ invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+	}, *lines)
+}
+
+func TestWithExportedProvidesOnly(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithExportedProvidesOnly())()
+	logger.LogEvent(&fxevent.Provided{
+		ConstructorName: "app.New()",
+		OutputTypeNames: []string{"*app.Server", "*app.cache", `[]app.Handler[group="handlers"]`, "string", `*app.db[name="primary"]`},
+	})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "app.newCache()", OutputTypeNames: []string{"*app.cache"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "app.newDB()", OutputTypeNames: []string{"*app.db"}, Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="provided" "constructor"="app.New()" "type"="*app.Server"`,
+		`"level"=0 "msg"="provided" "constructor"="app.New()" "type"="[]app.Handler[group=\"handlers\"]"`,
+		`"level"=0 "msg"="provided" "constructor"="app.newDB()" "type"="*app.db"`,
+		`"msg"="error encountered while applying options" "error"="some error"`,
+	}, *lines)
+}
+
+func TestWithHookConcurrency(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithHookConcurrency())()
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "b()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart"})
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "c()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "c()", CallerName: "main()", Method: "OnStart"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "b()", CallerName: "main()", Method: "OnStart"})
+
+	var counts []interface{}
+	for _, record := range recorder.Records() {
+		counts = append(counts, record.KeysAndValues[len(record.KeysAndValues)-1])
+	}
+	assert.Equal(t, []interface{}{1, 2, 1, 2, 1, 0}, counts)
+}
+
+func TestWithHookConcurrencyConcurrentLogging(t *testing.T) {
+	l, _ := newCapturingLogr()
+	logger := WithLogr(l, WithHookConcurrency())().(*LogrLogger)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "f()", CallerName: "main()"})
+			logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "f()", CallerName: "main()", Method: "OnStart"})
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, logger.run.hooks)
+}
+
+func TestWithCleanShutdownFlag(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithCleanShutdownFlag())()
+
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Stopped{})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{})
+
+	assert.Equal(t, []string{
+		`"msg"="OnStart hook failed" "error"="some error" "callee"="a()" "caller"="main()" "runtime"="0s"`,
+		`"level"=0 "msg"="shutdown" "clean"=false`,
+		`"level"=0 "msg"="started" "total_runtime"="0s"`,
+		`"level"=0 "msg"="shutdown" "clean"=true`,
+	}, *lines)
+}
+
+func TestWithLogLevel(t *testing.T) {
+	var lines []string
+	l := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 2})
+	logger := WithLogr(&l, WithLogLevel(2), WithErrorLevel(1))()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=2 "msg"="started" "total_runtime"="0s"`,
+		`"msg"="stop failed" "error"="some error"`,
+	}, lines)
+	assert.Equal(t, Config{LogLevel: 2, ErrorLevel: 1, Features: []string{}}, logger.(*LogrLogger).Config())
+}
+
+func TestUseEventLevel(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithLogLevel(1))().(*LogrLogger)
+	logger.UseEventLevel(map[string]int{"OnStartExecuting": 2, "Started": 0})
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart"})
+	logger.LogEvent(&fxevent.Started{})
+
+	var levels []int
+	for _, record := range recorder.Records() {
+		levels = append(levels, record.Level)
+	}
+	assert.Equal(t, []int{2, 1, 0}, levels)
+}
+
+func TestWithInvokingLevel(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithLogLevel(1), WithInvokingLevel(3))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+
+	var levels []int
+	for _, record := range recorder.Records() {
+		levels = append(levels, record.Level)
+	}
+	assert.Equal(t, []int{3, 1}, levels)
+}
+
+func TestWithCategoryNames(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger().WithName("fx")
+	logger := WithLogr(&l, WithCategoryNames(map[string]string{
+		"wiring":    "wiring",
+		"lifecycle": "lifecycle",
+	}), WithConfigSnapshot())()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.LoggerInitialized{ConstructorName: "fxlogr.WithLogr()"})
+
+	var names []string
+	for _, record := range recorder.Records() {
+		names = append(names, record.Name)
+	}
+	// The failed start ends the run, so the config is logged again before
+	// LoggerInitialized.
+	assert.Equal(t, []string{"fx", "fx/wiring", "fx/lifecycle", "fx", "fx"}, names)
+}
+
+func TestWithStartAttempts(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithStartAttempts(), WithClock(newFakeClock().Now))()
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.RollingBack{StartErr: errors.New("some error")})
+	logger.LogEvent(&fxevent.RolledBack{})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="OnStart hook executing" "callee"="a()" "caller"="main()" "attempt"=1`,
+		`"msg"="start failed, rolling back" "error"="some error" "attempt"=1`,
+		`"msg"="start failed" "error"="some error" "attempt"=1`,
+		`"level"=0 "msg"="OnStart hook executing" "callee"="a()" "caller"="main()" "attempt"=2`,
+		`"level"=0 "msg"="started" "total_runtime"="0s" "attempt"=2`,
+	}, *lines)
+}
+
+func TestWithKeyNames(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithKeyNames(KeyNames{Module: "component", Function: "fn", TotalRuntime: "hooks_runtime", SignalNumber: "signo", Phase: "stage"}), WithSignalNumber(true), WithPhaseField(true))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()", ModuleName: "app"})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", ModuleName: "app", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", ModuleName: "app", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopping{Signal: syscall.SIGTERM})
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "a()", CallerName: "main()"})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="invoking" "fn"="main.run()" "component"="app"`,
+		`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "component"="app" "type"="*bytes.Buffer"`,
+		`"msg"="invoke failed" "error"="some error" "stack"="" "fn"="main.run()" "component"="app"`,
+		`"level"=0 "msg"="started" "hooks_runtime"="0s"`,
+		`"level"=0 "msg"="received signal" "signal"="TERMINATED" "signo"=15`,
+		`"level"=0 "msg"="OnStop hook executing" "callee"="a()" "caller"="main()" "stage"="stop"`,
+	}, *lines)
+}
+
+func TestWithMessages(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithMessages(Messages{
+		OnStartExecuted: "hook ok",
+		OnStartFailed:   "hook KO",
+	}), WithMessageCase(MessageCaseUpper))()
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart", Runtime: time.Second})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "b()", CallerName: "main()", Method: "OnStart", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="ONSTART HOOK EXECUTING" "callee"="a()" "caller"="main()"`,
+		`"level"=0 "msg"="HOOK OK" "callee"="a()" "caller"="main()" "runtime"="1s"`,
+		`"msg"="HOOK KO" "error"="some error" "callee"="b()" "caller"="main()" "runtime"="0s"`,
+	}, *lines)
+}
+
+func TestWithEventFilter(t *testing.T) {
+	events := []fxevent.Event{
+		&fxevent.Supplied{TypeName: "*bytes.Buffer"},
+		&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}},
+		&fxevent.Invoking{FunctionName: "main.run()"},
+		&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")},
+		&fxevent.Started{},
+	}
+
+	tests := []struct {
+		name string
+		give func(fxevent.Event) bool
+		want []string
+	}{
+		{
+			name: "OnlyEvents",
+			give: OnlyEvents(&fxevent.Provided{}, &fxevent.Decorated{}, &fxevent.Invoked{}),
+			want: []string{
+				`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer"`,
+				`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+			},
+		},
+		{
+			name: "ExceptEvents",
+			give: ExceptEvents(&fxevent.Provided{}, &fxevent.Invoking{}),
+			want: []string{
+				`"level"=0 "msg"="supplied" "type"="*bytes.Buffer"`,
+				`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+				`"level"=0 "msg"="started" "total_runtime"="0s"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithEventFilter(tt.give))()
+		for _, event := range events {
+			logger.LogEvent(event)
+		}
+		assert.Equal(t, tt.want, *lines, tt.name)
+	}
+}
+
+func TestWithRedactor(t *testing.T) {
+	secret := regexp.MustCompile(`project[A-Z]\w*`)
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithRedactor(func(key, value string) string {
+		if key != "type" && key != "constructor" {
+			return value
+		}
+		return secret.ReplaceAllString(value, "REDACTED")
+	}))()
+
+	logger.LogEvent(&fxevent.Provided{
+		ConstructorName: "internal/projectFalcon.New()",
+		ModuleName:      "projectFalcon",
+		OutputTypeNames: []string{"*projectFalcon.Engine", "*bytes.Buffer"},
+	})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="provided" "constructor"="internal/REDACTED.New()" "module"="projectFalcon" "type"="*REDACTED.Engine"`,
+		`"level"=0 "msg"="provided" "constructor"="internal/REDACTED.New()" "module"="projectFalcon" "type"="*bytes.Buffer"`,
+	}, *lines)
+}
+
+func TestWithHideInternalModules(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithHideInternalModules("platform"))()
+
+	for _, module := range []string{"platform/db", "fxapp", "platform.private", ""} {
+		logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()", ModuleName: module})
+	}
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "example.com/platform/db.New()", OutputTypeNames: []string{"*db.DB"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "platform/db.New()", OutputTypeNames: []string{"*db.DB"}})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", ModuleName: "platform/db", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="invoking" "function"="main.run()" "module"="fxapp"`,
+		`"level"=0 "msg"="invoking" "function"="main.run()"`,
+		`"level"=0 "msg"="provided" "constructor"="example.com/platform/db.New()" "type"="*db.DB"`,
+		`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()" "module"="platform/db"`,
+	}, *lines)
+}
+
+func TestWithHideInternalModulesDefault(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	app := fx.New(
+		fx.WithLogger(WithLogr(&l, WithHideInternalModules())),
+		fx.Provide(func() *bytes.Buffer { return &bytes.Buffer{} }),
+		fx.Invoke(func(*bytes.Buffer) {}),
+	)
+	require.NoError(t, app.Err())
+
+	var provided []interface{}
+	for _, record := range recorder.Records() {
+		if record.Msg == "provided" {
+			provided = append(provided, record.KeysAndValues[1])
+		}
+	}
+	assert.Equal(t, []interface{}{"github.com/chaos-mesh/fx-logr.TestWithHideInternalModulesDefault.func1()"}, provided)
+}
+
+func TestWithEventKey(t *testing.T) {
+	events := []fxevent.Event{
+		&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A", "*B"}},
+		&fxevent.Invoking{FunctionName: "main.run()"},
+		&fxevent.Invoking{FunctionName: "main.run()"},
+		&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")},
+		&fxevent.Started{},
+		&fxevent.Stopped{Err: errors.New("some error")},
+		&fxevent.Started{},
+	}
+	keys := func() []interface{} {
+		recorder := NewLogRecorder()
+		l := recorder.Logger()
+		logger := WithLogr(&l, WithEventKey(), WithErrorSummary(), WithCleanShutdownFlag())()
+		for _, event := range events {
+			logger.LogEvent(event)
+		}
+
+		var keys []interface{}
+		for _, record := range recorder.Records() {
+			kvs := record.KeysAndValues
+			switch record.Msg {
+			case "errors summary", "shutdown":
+				assert.NotContains(t, kvs, "event_key", record.Msg)
+			default:
+				if assert.Equal(t, "event_key", kvs[len(kvs)-2], record.Msg) {
+					keys = append(keys, kvs[len(kvs)-1])
+				}
+			}
+		}
+		return keys
+	}
+
+	first := keys()
+	assert.Len(t, first, len(events)+1)
+	unique := make(map[interface{}]struct{})
+	for _, key := range first {
+		unique[key] = struct{}{}
+	}
+	assert.Len(t, unique, len(first))
+	assert.Equal(t, first, keys())
+}
+
+func TestWithNumericRuntime(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithNumericRuntime(time.Millisecond))()
+
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart", Runtime: 1500 * time.Microsecond})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "a()", CallerName: "main()", Runtime: 3 * time.Second})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="OnStart hook executed" "callee"="a()" "caller"="main()" "runtime"=1.5`,
+		`"level"=0 "msg"="OnStop hook executed" "callee"="a()" "caller"="main()" "runtime"=3000`,
+	}, *lines)
+}
+
+func TestWithAggregatedTypes(t *testing.T) {
+	events := []fxevent.Event{
+		&fxevent.Provided{ConstructorName: "app.New()", OutputTypeNames: []string{"*app.Server", "*app.Client"}},
+		&fxevent.Replaced{ModuleName: "app", OutputTypeNames: []string{"*app.Server", "*app.Client"}},
+		&fxevent.Decorated{DecoratorName: "app.Wrap()", OutputTypeNames: []string{"*app.Server", "*app.Client"}},
+	}
+
+	tests := []struct {
+		give bool
+		want []string
+	}{
+		{
+			give: true,
+			want: []string{
+				`"level"=0 "msg"="provided" "constructor"="app.New()" "types"=["*app.Server","*app.Client"]`,
+				`"level"=0 "msg"="replaced" "module"="app" "types"=["*app.Server","*app.Client"]`,
+				`"level"=0 "msg"="decorated" "decorator"="app.Wrap()" "types"=["*app.Server","*app.Client"]`,
+			},
+		},
+		{
+			give: false,
+			want: []string{
+				`"level"=0 "msg"="provided" "constructor"="app.New()" "type"="*app.Server"`,
+				`"level"=0 "msg"="provided" "constructor"="app.New()" "type"="*app.Client"`,
+				`"level"=0 "msg"="replaced" "module"="app" "type"="*app.Server"`,
+				`"level"=0 "msg"="replaced" "module"="app" "type"="*app.Client"`,
+				`"level"=0 "msg"="decorated" "decorator"="app.Wrap()" "type"="*app.Server"`,
+				`"level"=0 "msg"="decorated" "decorator"="app.Wrap()" "type"="*app.Client"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithAggregatedTypes(tt.give))()
+		for _, event := range events {
+			logger.LogEvent(event)
+		}
+		assert.Equal(t, tt.want, *lines)
+	}
+}
+
+func TestWithInvokeStackTrace(t *testing.T) {
+	tests := []struct {
+		give bool
+		want string
+	}{
+		{
+			give: true,
+			want: `"msg"="invoke failed" "error"="some error" "stack"="main.main" "function"="bytes.NewBuffer()" "module"="app"`,
+		},
+		{
+			give: false,
+			want: `"msg"="invoke failed" "error"="some error" "function"="bytes.NewBuffer()" "module"="app"`,
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithInvokeStackTrace(tt.give))()
+		logger.LogEvent(&fxevent.Invoked{FunctionName: "bytes.NewBuffer()", ModuleName: "app", Trace: "main.main", Err: errors.New("some error")})
+
+		assert.Equal(t, []string{tt.want}, *lines)
+	}
+}
+
+func TestWithName(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger().WithName("gateway")
+	logger := WithLogr(&l, WithName("fx"))()
+
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	records := recorder.Records()
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "gateway/fx", records[0].Name)
+		assert.Equal(t, "gateway/fx", records[1].Name)
+	}
+
+	// The logger passed to WithLogr is left as is.
+	l.Info("direct")
+	assert.Equal(t, "gateway", recorder.Records()[2].Name)
+}
+
+func TestWithBaseValues(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithBaseValues("app", "gateway", "instance", 1))()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()", ModuleName: "app"})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="invoking" "app"="gateway" "instance"=1 "function"="main.run()" "module"="app"`,
+		`"msg"="stop failed" "error"="some error" "app"="gateway" "instance"=1`,
+	}, *lines)
+}
+
+func TestNew(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithLogLevel(0))
+	logger.UseErrorLevel(0)
+
+	var _ fxevent.Logger = logger
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{`"level"=0 "msg"="started" "total_runtime"="0s"`}, *lines)
+}
+
+// futureEvent stands for an event added to fxevent after this package.
+type futureEvent struct {
+	*fxevent.Started
+}
+
+func TestUnknownEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		give []Option
+		want []string
+	}{
+		{
+			name: "Default",
+			want: []string{`"level"=0 "msg"="unhandled fx event" "event_type"="fxlogr.futureEvent"`},
+		},
+		{
+			name: "Disabled",
+			give: []Option{WithLogUnknownEvents(false)},
+		},
+		{
+			name: "Renamed",
+			give: []Option{WithMessages(Messages{Unhandled: "unknown event"}), WithKeyNames(KeyNames{EventType: "go_type"})},
+			want: []string{`"level"=0 "msg"="unknown event" "go_type"="fxlogr.futureEvent"`},
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, tt.give...)()
+		logger.LogEvent(futureEvent{&fxevent.Started{}})
+
+		assert.Equal(t, tt.want, *lines, tt.name)
+	}
+}
+
+func TestWithEventObserver(t *testing.T) {
+	counts := make(map[string]int)
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l,
+		WithEventObserver(func(event fxevent.Event, isError bool) {
+			counts[fmt.Sprintf("%s/%t", eventType(event), isError)]++
+		}),
+		WithEventFilter(OnlyEvents(&fxevent.Invoked{})),
+	)()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "b()", OutputTypeNames: []string{"*B"}})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")})
+
+	assert.Equal(t, map[string]int{"Provided/false": 2, "Invoked/false": 1, "Invoked/true": 1}, counts)
+	assert.Len(t, *lines, 1)
+}
+
+func TestWithRuntimeObserver(t *testing.T) {
+	var observed []string
+	l, _ := newCapturingLogr()
+	logger := WithLogr(l,
+		WithRuntimeObserver(func(eventType, name string, d time.Duration) {
+			observed = append(observed, fmt.Sprintf("%s/%s/%s", eventType, name, d))
+		}),
+		WithEnableFunc(func() bool { return false }),
+	)()
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "hook.onStart"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "hook.onStart", Runtime: time.Millisecond})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop", Runtime: time.Second, Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		"OnStartExecuted/hook.onStart/1ms",
+		"OnStopExecuted/hook.onStop/1s",
+	}, observed)
+}
+
+func TestWithSampler(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSampler(EveryN(3)))()
+
+	for i := 0; i < 7; i++ {
+		logger.LogEvent(&fxevent.Provided{ConstructorName: fmt.Sprintf("new%d()", i), OutputTypeNames: []string{"int"}})
+	}
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="provided" "constructor"="new0()" "type"="int" "sampled"=true "sample_rate"=3`,
+		`"level"=0 "msg"="provided" "constructor"="new3()" "type"="int" "sampled"=true "sample_rate"=3`,
+		`"level"=0 "msg"="provided" "constructor"="new6()" "type"="int" "sampled"=true "sample_rate"=3`,
+		`"level"=0 "msg"="invoking" "function"="main.run()" "sampled"=true "sample_rate"=3`,
+		`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+		`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+	}, *lines)
+}
+
+func TestWithSamplerFunc(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSampler(SamplerFunc(func(event fxevent.Event) bool {
+		_, ok := event.(*fxevent.Invoking)
+		return ok
+	})))()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A"}})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	assert.Equal(t, []string{`"level"=0 "msg"="invoking" "function"="main.run()"`}, *lines)
+}
+
+func TestEveryNConcurrent(t *testing.T) {
+	sample := EveryN(4)
+	var mu sync.Mutex
+	kept := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if keep, _ := sample.Sample(&fxevent.Provided{}); keep {
+				mu.Lock()
+				kept++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 25, kept)
+}
+
+func TestUseLevelConcurrently(t *testing.T) {
+	l, _ := newCapturingLogr()
+	logger := New(l)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.UseLogLevel(i % 2)
+			logger.UseErrorLevel(i % 2)
+			logger.UseEventLevel(map[string]int{"Started": i % 2})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.LogEvent(&fxevent.Started{})
+			logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+		}
+	}()
+	wg.Wait()
+}
+
+// customSignal is a signal that is not a syscall.Signal.
+type customSignal struct{}
+
+func (customSignal) String() string { return "custom" }
+func (customSignal) Signal()        {}
+
+func TestWithSignalNumber(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithSignalNumber(true))()
+
+	logger.LogEvent(&fxevent.Stopping{Signal: syscall.SIGINT})
+	logger.LogEvent(&fxevent.Stopping{Signal: customSignal{}})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="received signal" "signal"="INTERRUPT" "signal_num"=2`,
+		`"level"=0 "msg"="received signal" "signal"="CUSTOM"`,
+	}, *lines)
+}
+
+func TestWithRuntimePrecision(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithRuntimePrecision(time.Millisecond))()
+
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart", Runtime: 3214998 * time.Nanosecond})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "a()", CallerName: "main()", Runtime: 1500 * time.Microsecond})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="OnStart hook executed" "callee"="a()" "caller"="main()" "runtime"="3ms"`,
+		`"level"=0 "msg"="OnStop hook executed" "callee"="a()" "caller"="main()" "runtime"="2ms"`,
+	}, *lines)
+}
+
+func TestModuleField(t *testing.T) {
+	someError := errors.New("some error")
+	events := func(module string) []fxevent.Event {
+		return []fxevent.Event{
+			&fxevent.Supplied{TypeName: "int", ModuleName: module},
+			&fxevent.Supplied{TypeName: "int", ModuleName: module, Err: someError},
+			&fxevent.Provided{ConstructorName: "f()", OutputTypeNames: []string{"int"}, ModuleName: module},
+			&fxevent.Provided{ConstructorName: "f()", ModuleName: module, Err: someError},
+			&fxevent.Replaced{OutputTypeNames: []string{"int"}, ModuleName: module},
+			&fxevent.Replaced{ModuleName: module, Err: someError},
+			&fxevent.Decorated{DecoratorName: "f()", OutputTypeNames: []string{"int"}, ModuleName: module},
+			&fxevent.Decorated{DecoratorName: "f()", ModuleName: module, Err: someError},
+			&fxevent.Invoking{FunctionName: "f()", ModuleName: module},
+			&fxevent.Invoked{FunctionName: "f()", ModuleName: module, Err: someError},
+		}
+	}
+
+	for _, module := range []string{"app", ""} {
+		recorder := NewLogRecorder()
+		l := recorder.Logger()
+		logger := New(&l)
+		for _, event := range events(module) {
+			logger.LogEvent(event)
+		}
+
+		records := recorder.Records()
+		assert.Len(t, records, 10)
+		for _, record := range records {
+			if module == "" {
+				assert.NotContains(t, record.KeysAndValues, "module", record.Msg)
+			} else {
+				assert.Contains(t, record.KeysAndValues, "module", record.Msg)
+				assert.Contains(t, record.KeysAndValues, module, record.Msg)
+			}
+		}
+	}
+}
+
+func TestNewNop(t *testing.T) {
+	app := fx.New(
+		fx.WithLogger(NewNop),
+		fx.Provide(func() int { return 1 }),
+		fx.Invoke(func(int) error { return errors.New("some error") }),
+	)
+	assert.Error(t, app.Err())
+}
+
+func TestNilLogger(t *testing.T) {
+	events := []fxevent.Event{
+		&fxevent.Started{},
+		&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")},
+	}
+
+	for _, logger := range []fxevent.Logger{
+		WithLogr(nil, WithName("fx"), WithBaseValues("app", "gateway"))(),
+		&LogrLogger{},
+	} {
+		assert.NotPanics(t, func() {
+			for _, event := range events {
+				logger.LogEvent(event)
+			}
+		})
+	}
+}
+
+func TestWithExplicitPrivate(t *testing.T) {
+	tests := []struct {
+		give bool
+		want []string
+	}{
+		{
+			give: false,
+			want: []string{
+				`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer"`,
+				`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer" "private"=true`,
+			},
+		},
+		{
+			give: true,
+			want: []string{
+				`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer" "private"=false`,
+				`"level"=0 "msg"="provided" "constructor"="bytes.NewBuffer()" "type"="*bytes.Buffer" "private"=true`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l, lines := newCapturingLogr()
+		logger := WithLogr(l, WithExplicitPrivate(tt.give))()
+		logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+		logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}, Private: true})
+
+		assert.Equal(t, tt.want, *lines)
+	}
+}
+
+type traceIDKey struct{}
+
+func TestLogEventCtx(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithContextValues(func(ctx context.Context) []interface{} {
+		if id, ok := ctx.Value(traceIDKey{}).(string); ok {
+			return []interface{}{"trace_id", id}
+		}
+		return nil
+	}))
+
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "abc")
+	logger.LogEventCtx(ctx, &fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	logger.LogEventCtx(ctx, &fxevent.Stopped{Err: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="OnStart hook executing" "trace_id"="abc" "callee"="a()" "caller"="main()"`,
+		`"msg"="stop failed" "error"="some error" "trace_id"="abc"`,
+		`"level"=0 "msg"="started" "total_runtime"="0s"`,
+	}, *lines)
+}
+
+func TestWithErrorPolicy(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := New(&l, WithErrorLevel(1), WithErrorPolicy(func(event fxevent.Event) (bool, int) {
+		if _, ok := event.(*fxevent.RollingBack); ok {
+			return false, 2
+		}
+		return true, 0
+	}))
+
+	logger.LogEvent(&fxevent.RollingBack{StartErr: errors.New("some error")})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+
+	records := recorder.Records()
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, Record{
+			Level:         2,
+			Msg:           "start failed, rolling back",
+			KeysAndValues: []interface{}{"error", "some error"},
+		}, records[0])
+		assert.True(t, records[1].IsError)
+	}
+}
+
+func TestWithErrorPolicyDecorations(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithErrorSummary(), WithErrorChain(true), WithErrorPolicy(func(fxevent.Event) (bool, int) {
+		return false, 0
+	}))
+
+	logger.LogEvent(&fxevent.Stopped{Err: fmt.Errorf("stop: %w", errors.New("some error"))})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="stop failed" "error"="stop: some error" "errors"=["some error"]`,
+		`"level"=0 "msg"="errors summary" "count"=1 "errors"=["stop: some error"]`,
+	}, *lines)
+}
+
+func TestWithStartupSummary(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := New(l, WithStartupSummary(true), WithClock(clock.Now))
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A"}})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "b()", OutputTypeNames: []string{"*B"}})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "c()", OutputTypeNames: []string{"*A"}})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()"})
+	clock.Add(250 * time.Millisecond)
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, `"level"=0 "msg"="fx startup summary" "provided"=2 "decorated"=1 "invoked"=1 "startup_time"="250ms"`, (*lines)[len(*lines)-1])
+}
+
+func TestStartedTotalRuntime(t *testing.T) {
+	clock := newFakeClock()
+	l, lines := newCapturingLogr()
+	logger := New(l, WithClock(clock.Now))
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A"}})
+	clock.Add(time.Second)
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "a()", CallerName: "main()"})
+	clock.Add(200 * time.Millisecond)
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "a()", CallerName: "main()", Method: "OnStart", Runtime: 200 * time.Millisecond})
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "b()", CallerName: "main()"})
+	clock.Add(300 * time.Millisecond)
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "b()", CallerName: "main()", Method: "OnStart", Runtime: 300 * time.Millisecond})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, `"level"=0 "msg"="started" "total_runtime"="500ms"`, (*lines)[len(*lines)-1])
+}
+
+func TestDisabledLevel(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithLogLevel(1))
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "a()", OutputTypeNames: []string{"*A"}})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"msg"="invoke failed" "error"="some error" "stack"="" "function"="main.run()"`,
+	}, *lines)
+}
+
+func BenchmarkLogEventDisabled(b *testing.B) {
+	l := funcr.New(func(_, _ string) {}, funcr.Options{})
+	logger := New(&l, WithLogLevel(1))
+	event := &fxevent.Provided{
+		ConstructorName: "bytes.NewBuffer()",
+		ModuleName:      "app",
+		OutputTypeNames: []string{"*bytes.Buffer", "io.Writer", "io.Reader"},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogEvent(event)
+	}
+}
+
+func BenchmarkProvidedManyTypes(b *testing.B) {
+	l := funcr.New(func(_, _ string) {}, funcr.Options{})
+	logger := New(&l)
+	types := make([]string, 50)
+	for i := range types {
+		types[i] = fmt.Sprintf("*pkg.Type%d", i)
+	}
+	event := &fxevent.Provided{ConstructorName: "pkg.New()", ModuleName: "app", OutputTypeNames: types}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogEvent(event)
+	}
+}
+
+func TestWithTypeFormatter(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithTypeFormatter(func(name string) string {
+		return strings.TrimPrefix(strings.ReplaceAll(name, "github.com/acme/app/", ""), "*")
+	}))
+
+	logger.LogEvent(&fxevent.Supplied{TypeName: "*github.com/acme/app/config.Config"})
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "github.com/acme/app/db.New()", OutputTypeNames: []string{"*github.com/acme/app/db.DB"}})
+	logger.LogEvent(&fxevent.Replaced{OutputTypeNames: []string{"*github.com/acme/app/db.DB"}})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "github.com/acme/app/db.Wrap()", OutputTypeNames: []string{"*github.com/acme/app/db.DB"}})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="supplied" "type"="config.Config"`,
+		`"level"=0 "msg"="provided" "constructor"="db.New()" "type"="db.DB"`,
+		`"level"=0 "msg"="replaced" "type"="db.DB"`,
+		`"level"=0 "msg"="decorated" "decorator"="db.Wrap()" "type"="db.DB"`,
+	}, *lines)
+}
+
+func TestWithTypeFormatterDerivedLines(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithTypeFormatter(func(name string) string {
+		return strings.ReplaceAll(name, "github.com/acme/app/", "")
+	}), WithTypeDiff(func(name string) ([]string, bool) {
+		return []string{"*github.com/acme/app/db.DB"}, name == "github.com/acme/app/db.New()"
+	}), WithModuleProvideSummary(), WithoutTypeDetails())
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "github.com/acme/app/db.New()", ModuleName: "db", OutputTypeNames: []string{"*github.com/acme/app/db.DB"}})
+	logger.LogEvent(&fxevent.Decorated{DecoratorName: "github.com/acme/app/db.Wrap()", OutputTypeNames: []string{"*github.com/acme/app/db.DB"}})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="provided" "constructor"="db.New()" "module"="db"`,
+		`"level"=0 "msg"="output types" "constructor"="db.New()" "output_count"=1 "expected_count"=1 "missing"=[] "unexpected"=[]`,
+		`"level"=0 "msg"="decorated" "decorator"="db.Wrap()"`,
+		`"level"=0 "msg"="output types" "decorator"="db.Wrap()" "output_count"=1`,
+		`"level"=0 "msg"="started" "total_runtime"="0s"`,
+		`"level"=0 "msg"="module provides" "module"="db" "count"=1 "constructors"=["db.New()"]`,
+	}, *lines)
+}
+
+func TestWithPhaseField(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := New(l, WithPhaseField(true))
+
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "hook.onStart", CallerName: "bytes.NewBuffer"})
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "hook.onStart", CallerName: "bytes.NewBuffer", Runtime: time.Millisecond})
+	logger.LogEvent(&fxevent.OnStopExecuting{FunctionName: "hook.onStop", CallerName: "bytes.NewBuffer"})
+	logger.LogEvent(&fxevent.OnStopExecuted{FunctionName: "hook.onStop", CallerName: "bytes.NewBuffer", Runtime: time.Millisecond})
+	logger.LogEvent(&fxevent.Stopping{Signal: os.Interrupt})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="OnStart hook executing" "callee"="hook.onStart" "caller"="bytes.NewBuffer" "phase"="start"`,
+		`"level"=0 "msg"="OnStart hook executed" "callee"="hook.onStart" "caller"="bytes.NewBuffer" "runtime"="1ms" "phase"="start"`,
+		`"level"=0 "msg"="OnStop hook executing" "callee"="hook.onStop" "caller"="bytes.NewBuffer" "phase"="stop"`,
+		`"level"=0 "msg"="OnStop hook executed" "callee"="hook.onStop" "caller"="bytes.NewBuffer" "runtime"="1ms" "phase"="stop"`,
+		`"level"=0 "msg"="received signal" "signal"="INTERRUPT"`,
+	}, *lines)
+}
+
+func TestWithLogSink(t *testing.T) {
+	l, lines := newCapturingLogr()
+	logger := WithLogSink(l.GetSink())()
+
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Started{Err: errors.New("some error")})
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="invoking" "function"="main.run()"`,
+		`"msg"="start failed" "error"="some error"`,
+	}, *lines)
+}
+
+func TestLogrLoggerAccessor(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := New(&l, WithName("fx"), WithBaseValues("app", "gateway"))
+
+	logger.LogrLogger().Info("custom line", "key", "value")
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+
+	assert.Equal(t, []Record{
+		{Name: "fx", Msg: "custom line", KeysAndValues: []interface{}{"app", "gateway", "key", "value"}},
+		{Name: "fx", Msg: "invoking", KeysAndValues: []interface{}{"app", "gateway", "function", "main.run()"}},
+	}, recorder.Records())
+	assert.Nil(t, (&LogrLogger{}).LogrLogger().GetSink())
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "go.uber.org/fx/fxevent"
+
+// MultiLogger is a fxevent.Logger passing every event to each of its loggers,
+// in order.
+type MultiLogger []fxevent.Logger
+
+var _ fxevent.Logger = MultiLogger(nil)
+
+func (m MultiLogger) LogEvent(event fxevent.Event) {
+	for _, l := range m {
+		if l != nil {
+			l.LogEvent(event)
+		}
+	}
+}
+
+// WithLoggers returns a function that returns a fxevent.Logger passing every
+// event to each of loggers, for instance a LogrLogger and a custom collector.
+// Nil loggers are skipped.
+func WithLoggers(loggers ...fxevent.Logger) func() fxevent.Logger {
+	return func() fxevent.Logger {
+		return MultiLogger(append([]fxevent.Logger(nil), loggers...))
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithLoggers(t *testing.T) {
+	l, lines := newCapturingLogr()
+	recorder := &eventRecorder{}
+
+	app := fx.New(
+		fx.WithLogger(WithLoggers(New(l), nil, recorder)),
+		fx.Provide(func() int { return 1 }),
+	)
+	require.NoError(t, app.Err())
+
+	assert.NotEmpty(t, recorder.events)
+	assert.Len(t, *lines, len(recorder.events))
+	assert.IsType(t, &fxevent.LoggerInitialized{}, recorder.events[len(recorder.events)-1])
+}
@@ -0,0 +1,1084 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/fx/fxevent"
+)
+
+// Option configures a LogrLogger created by New or WithLogr.
+type Option func(*LogrLogger)
+
+// WithLogLevel sets the log level for log events, like UseLogLevel.
+func WithLogLevel(level int) Option {
+	return func(l *LogrLogger) {
+		l.UseLogLevel(level)
+	}
+}
+
+// WithErrorLevel sets the log level for error events, like UseErrorLevel.
+func WithErrorLevel(level int) Option {
+	return func(l *LogrLogger) {
+		l.UseErrorLevel(level)
+	}
+}
+
+// WithInvokingLevel sets the log level for Invoking events, which are logged
+// for every invoke, independently of the log level of other events. Levels set
+// with UseEventLevel or WithModulePattern take precedence.
+func WithInvokingLevel(level int) Option {
+	return func(l *LogrLogger) {
+		l.invokingLevel = &level
+	}
+}
+
+// WithNamedLevel sets the log level for log events to the level named name,
+// as resolved by ParseLevel. Unknown names leave the log level unchanged.
+func WithNamedLevel(name string) Option {
+	return func(l *LogrLogger) {
+		if level, ok := ParseLevel(name); ok {
+			l.UseLogLevel(level)
+		}
+	}
+}
+
+// WithGoVersion stamps every event with the Go runtime version under the
+// "go_version" key.
+func WithGoVersion() Option {
+	return func(l *LogrLogger) {
+		l.goVersion = true
+	}
+}
+
+// WithDeferUntilStarted holds back all events until Started is received, then
+// logs them in order followed by Started itself. An event carrying an error,
+// such as the failed Invoked of an fx.New that never starts, ends the deferral
+// the same way. The buffered events are kept so that they can be sent
+// elsewhere with ReplayBuffered.
+func WithDeferUntilStarted() Option {
+	return func(l *LogrLogger) {
+		l.deferUntilStarted = true
+	}
+}
+
+// WithAdapterStack attaches the Go stack captured at the point of logging under
+// the "adapter_stack" key to error events that do not carry a stack from fx.
+// Capturing the stack is not free, so this is disabled by default.
+func WithAdapterStack() Option {
+	return func(l *LogrLogger) {
+		l.adapterStack = true
+	}
+}
+
+// WithStartFailureStack attaches the Go stack captured at the point of logging
+// under the "adapter_stack" key to failed Started events only. It is disabled
+// by default.
+func WithStartFailureStack() Option {
+	return func(l *LogrLogger) {
+		l.startFailStack = true
+	}
+}
+
+// WithErrorSummary logs an "errors summary" event on Stopped with the number of
+// errors seen during the run and the first of their messages.
+func WithErrorSummary() Option {
+	return func(l *LogrLogger) {
+		l.errorSummary = true
+	}
+}
+
+// MessageCase is the casing applied to event messages.
+type MessageCase int
+
+const (
+	// MessageCaseNone keeps messages as they are.
+	MessageCaseNone MessageCase = iota
+	// MessageCaseLower converts messages to lower case.
+	MessageCaseLower
+	// MessageCaseUpper converts messages to upper case.
+	MessageCaseUpper
+	// MessageCaseTitle capitalizes the first letter of every word.
+	MessageCaseTitle
+)
+
+func (c MessageCase) apply(msg string) string {
+	switch c {
+	case MessageCaseLower:
+		return strings.ToLower(msg)
+	case MessageCaseUpper:
+		return strings.ToUpper(msg)
+	case MessageCaseTitle:
+		words := strings.Split(msg, " ")
+		for i, w := range words {
+			r, size := utf8.DecodeRuneInString(w)
+			if size > 0 {
+				words[i] = string(unicode.ToUpper(r)) + w[size:]
+			}
+		}
+		return strings.Join(words, " ")
+	default:
+		return msg
+	}
+}
+
+// WithMessageCase applies the given casing to the message of every event.
+func WithMessageCase(c MessageCase) Option {
+	return func(l *LogrLogger) {
+		l.messageCase = c
+	}
+}
+
+// WithRunNumber stamps every event with the number of the run it belongs to
+// under the "run_number" key. Runs are counted from 1 for the lifetime of the
+// logger; a run ends with Stopped or a failed Started.
+func WithRunNumber() Option {
+	return func(l *LogrLogger) {
+		l.runNumber = true
+	}
+}
+
+// modulePattern is a module name pattern registered with WithModulePattern.
+type modulePattern struct {
+	pattern string
+	level   int
+}
+
+// WithModulePattern logs non-error events of the modules whose name matches
+// pattern at the given level. Patterns use the syntax of path.Match, so
+// "internal/*" matches "internal/db" but not "internal/db/migrations". When
+// several patterns match a module, the first one registered wins. Error events
+// are always logged at the error level.
+func WithModulePattern(pattern string, level int) Option {
+	return func(l *LogrLogger) {
+		l.modulePatterns = append(l.modulePatterns, modulePattern{pattern: pattern, level: level})
+	}
+}
+
+// WithSampling logs only the first of every n events. Events carrying an error
+// are always logged. Sampled events are stamped with "sampled"=true and the
+// rate under "sample_rate", so that consumers can weight their counts.
+func WithSampling(n int) Option {
+	return func(l *LogrLogger) {
+		l.sampleRate = n
+	}
+}
+
+// WithEventSampling logs only the first of every n events of each type, with
+// n taken from rates keyed by the name of the fxevent type, such as
+// "Provided". Types missing from rates fall back to WithSampling. Events
+// carrying an error are always logged, and sampled events are annotated as
+// with WithSampling.
+func WithEventSampling(rates map[string]int) Option {
+	return func(l *LogrLogger) {
+		l.eventSampling = rates
+	}
+}
+
+// WithEnableFunc calls enabled at the start of every LogEvent and drops the
+// event when it returns false, so that fx logging can be toggled at runtime.
+func WithEnableFunc(enabled func() bool) Option {
+	return func(l *LogrLogger) {
+		l.enabled = enabled
+	}
+}
+
+// WithErrorsBypassEnableFunc logs events carrying an error even when the
+// function set with WithEnableFunc returns false.
+func WithErrorsBypassEnableFunc() Option {
+	return func(l *LogrLogger) {
+		l.bypassGate = true
+	}
+}
+
+// WithConfigSnapshot logs a "config" event describing the active configuration
+// at the start of every run, so that logs are self-describing.
+func WithConfigSnapshot() Option {
+	return func(l *LogrLogger) {
+		l.configSnapshot = true
+	}
+}
+
+// WithFriendlyErrors replaces fx missing dependency errors with a concise
+// "missing dependency: <type>" error and keeps the original message under the
+// "raw_error" key. See newFriendlyError for how these errors are detected.
+func WithFriendlyErrors() Option {
+	return func(l *LogrLogger) {
+		l.friendlyErrors = true
+	}
+}
+
+// WithTraceExtractor stamps every line with the trace and span IDs returned by
+// extract under the "trace_id" and "span_id" keys. Empty IDs are omitted.
+func WithTraceExtractor(extract func() (traceID, spanID string)) Option {
+	return func(l *LogrLogger) {
+		l.traceExtractor = extract
+	}
+}
+
+// WithClock sets the function used to read the current time. It defaults to
+// time.Now and is mostly useful in tests.
+func WithClock(now func() time.Time) Option {
+	return func(l *LogrLogger) {
+		l.clock = now
+	}
+}
+
+// WithElapsedSinceStart stamps every event with the time elapsed since the
+// first event of the run under the "elapsed_since_start" key.
+func WithElapsedSinceStart() Option {
+	return func(l *LogrLogger) {
+		l.elapsed = true
+	}
+}
+
+// WithAdapterVersion stamps every event with the version of fx-logr recorded
+// in the build info of the binary under the "adapter_version" key.
+func WithAdapterVersion() Option {
+	return func(l *LogrLogger) {
+		l.version = adapterVersion()
+	}
+}
+
+// WithTypedSink passes every event to sink as is, before it is logged. Combined
+// with logr.Discard, this hands the raw events to sink without any formatting.
+func WithTypedSink(sink func(fxevent.Event)) Option {
+	return func(l *LogrLogger) {
+		l.typedSink = sink
+	}
+}
+
+// WithStartupBudget logs a "startup exceeded budget" event on Started when
+// more than budget elapsed since the first event of the run.
+func WithStartupBudget(budget time.Duration) Option {
+	return func(l *LogrLogger) {
+		l.startupBudget = budget
+	}
+}
+
+// WithModuleCount logs a "modules loaded" event on Started with the number of
+// distinct modules seen during the run under the "module_count" key.
+func WithModuleCount() Option {
+	return func(l *LogrLogger) {
+		l.moduleCount = true
+	}
+}
+
+// WithFailureTail keeps the last n events of the run that are logged as at
+// least one line and, on the first error of the run, logs them again at the
+// error level, oldest first, right before the error. These lines are marked
+// with "failure_tail"=true. As the events are kept whatever their level or
+// sampling, this gives the context of a failure even when the events leading
+// to it were not logged.
+func WithFailureTail(n int) Option {
+	return func(l *LogrLogger) {
+		l.failureTail = n
+	}
+}
+
+// WithErrorFieldsFunc appends the key/value pairs returned by fields for the
+// error of every error event, so that structured data carried by errors is
+// logged as fields.
+func WithErrorFieldsFunc(fields func(error) []interface{}) Option {
+	return func(l *LogrLogger) {
+		l.errorFields = fields
+	}
+}
+
+// WithDeterministic makes the output of the logger reproducible, for golden
+// tests: the clock is fixed at the Unix epoch, so that every time-derived
+// field has the same value from one run to the next.
+func WithDeterministic() Option {
+	return func(l *LogrLogger) {
+		epoch := time.Unix(0, 0).UTC()
+		l.clock = func() time.Time { return epoch }
+	}
+}
+
+// WithFQTypeNames logs type names as returned by resolve, so that short names
+// like "*bytes.Buffer" can be expanded to unambiguous ones. It applies to the
+// type of Supplied events and the output types of Provided, Replaced and
+// Decorated events.
+func WithFQTypeNames(resolve func(string) string) Option {
+	return func(l *LogrLogger) {
+		l.fqTypeNames = resolve
+	}
+}
+
+// WithSplitMultierror logs one error event per underlying error, with the same
+// message and fields, when the error of an event combines several errors.
+func WithSplitMultierror() Option {
+	return func(l *LogrLogger) {
+		l.splitMultierror = true
+	}
+}
+
+const (
+	// errorSeverityKey is the key of the tier of error events in the map
+	// given to WithSeverityTierMap.
+	errorSeverityKey = "error"
+	// defaultErrorSeverity is the tier of error events when the map given to
+	// WithSeverityTierMap has none.
+	defaultErrorSeverity = 8
+)
+
+// WithSeverityTierMap stamps events with a numeric severity under the "sev"
+// key, for SIEM ingestion. Non-error events get the tier of their fxevent type
+// name in tiers, such as "Provided", and no "sev" when their type is missing.
+// Error events get the tier under the "error" key, or 8 when it is missing.
+func WithSeverityTierMap(tiers map[string]int) Option {
+	return func(l *LogrLogger) {
+		l.severityTiers = tiers
+	}
+}
+
+// WithMaxEventsPerSecond drops events beyond n per second, with bursts of up to
+// n events. Events carrying an error are never dropped unless
+// WithThrottleErrors is set. The first line logged after events were dropped
+// carries their number under the "dropped" key.
+func WithMaxEventsPerSecond(n int) Option {
+	return func(l *LogrLogger) {
+		l.maxPerSecond = n
+	}
+}
+
+// WithThrottleErrors makes WithMaxEventsPerSecond drop events carrying an error
+// as well.
+func WithThrottleErrors() Option {
+	return func(l *LogrLogger) {
+		l.throttleErrors = true
+	}
+}
+
+// TypeDiffResolver returns the output types expected from the constructor or
+// decorator with the given name, and false when it has no expectation.
+type TypeDiffResolver func(name string) (expected []string, ok bool)
+
+// WithTypeDiff logs an "output types" event after every successful Provided
+// and Decorated event with the number of output types under "output_count".
+// When resolve has expectations for the constructor or decorator, the event
+// also carries "expected_count", the expected types missing from the outputs
+// under "missing", and the outputs that were not expected under "unexpected".
+func WithTypeDiff(resolve TypeDiffResolver) Option {
+	return func(l *LogrLogger) {
+		l.typeDiff = resolve
+	}
+}
+
+// WithoutTypeDetails logs Provided, Replaced and Decorated events once, with
+// their constructor or decorator and module but without their output types.
+func WithoutTypeDetails() Option {
+	return func(l *LogrLogger) {
+		l.withoutTypes = true
+	}
+}
+
+// WithStopStallHeartbeat logs a "stop hook still running" event every interval
+// for each OnStop hook that has been executing for at least interval, until
+// its OnStopExecuted event is received.
+func WithStopStallHeartbeat(interval time.Duration) Option {
+	return func(l *LogrLogger) {
+		l.stopHeartbeat = interval
+	}
+}
+
+// WithTimestamp stamps every line with the current time formatted with layout,
+// as in time.Time.Format, under key. This is meant for sinks that do not
+// timestamp lines themselves.
+func WithTimestamp(key, layout string) Option {
+	return func(l *LogrLogger) {
+		l.timestampKey = key
+		l.timestampLayout = layout
+	}
+}
+
+// WithOptionCount logs an "options" event once per run, when the first event
+// that is not a Supplied, Provided, Replaced or Decorated event is received,
+// with the number of those events seen until then under the "count" key.
+//
+// fx does not report how many fx.Option values an application was built with,
+// so this count of the options that were applied is only a proxy for it:
+// options such as fx.Invoke or fx.WithLogger are not counted, and a
+// constructor providing several types counts once.
+func WithOptionCount() Option {
+	return func(l *LogrLogger) {
+		l.optionCount = true
+	}
+}
+
+// WithAnnounceInit logs a "fx-logr initialized" event the first time the
+// logger logs an event, to mark when it became active. The line belongs to the
+// first run, as the event does.
+func WithAnnounceInit() Option {
+	return func(l *LogrLogger) {
+		l.announceInit = true
+	}
+}
+
+// WithOwnershipMap stamps the events of the modules in owners with the owner
+// of their module under the "owner" key, so that alerts built on the logs can
+// be routed to the right team. Other events get the owner set with
+// WithDefaultOwner, if any.
+func WithOwnershipMap(owners map[string]string) Option {
+	return func(l *LogrLogger) {
+		l.owners = owners
+	}
+}
+
+// WithDefaultOwner sets the owner stamped on events whose module is not in the
+// map given to WithOwnershipMap, including events without a module.
+func WithDefaultOwner(owner string) Option {
+	return func(l *LogrLogger) {
+		l.defaultOwner = owner
+	}
+}
+
+// WithErrorTemplater logs errors with their message passed through template,
+// which is meant to strip variable parts such as addresses or IDs so that
+// errors can be grouped by message. The original message is kept under the
+// "raw_error" key when it is changed.
+func WithErrorTemplater(template func(string) string) Option {
+	return func(l *LogrLogger) {
+		l.errorTemplater = template
+	}
+}
+
+// WithModuleProvideSummary logs a "module provides" event per module on
+// Started, with the number of constructors the module provided during the run
+// under "count" and the first ten of them under "constructors". Constructors
+// of the root application are reported without a module.
+func WithModuleProvideSummary() Option {
+	return func(l *LogrLogger) {
+		l.provideSummary = true
+	}
+}
+
+// Symbols are the prefixes WithSymbols adds to messages.
+type Symbols struct {
+	// Success prefixes events reporting something that was done.
+	Success string
+	// Failure prefixes error events.
+	Failure string
+	// Progress prefixes events reporting something that is starting.
+	Progress string
+}
+
+var (
+	// DefaultSymbols are the symbols used by WithSymbols.
+	DefaultSymbols = Symbols{Success: "✓", Failure: "✗", Progress: "→"}
+	// ASCIISymbols are symbols for terminals lacking unicode support.
+	ASCIISymbols = Symbols{Success: "+", Failure: "x", Progress: ">"}
+)
+
+func (s *Symbols) symbol(eventType string, isError bool) string {
+	if isError {
+		return s.Failure
+	}
+	switch eventType {
+	case "OnStartExecuting", "OnStopExecuting", "Invoking", "Stopping":
+		return s.Progress
+	}
+	return s.Success
+}
+
+// WithSymbols prefixes messages with a symbol for their category, from
+// DefaultSymbols, to make console logs easier to scan.
+func WithSymbols() Option {
+	return WithSymbolSet(DefaultSymbols)
+}
+
+// WithSymbolSet is WithSymbols with the given symbols, such as ASCIISymbols.
+func WithSymbolSet(symbols Symbols) Option {
+	return func(l *LogrLogger) {
+		l.symbols = &symbols
+	}
+}
+
+// WithTimeline records every event of the run in a timeline that can be read
+// with Timeline. It does not change what is logged.
+func WithTimeline() Option {
+	return func(l *LogrLogger) {
+		l.timeline = true
+	}
+}
+
+// WithSuppressRepeatSupplies logs a Supplied event only the first time its type
+// is supplied by its module over the lifetime of the logger, which quiets
+// applications restarted in the same process. The number of Supplied events
+// dropped during a run is logged on Started as a "suppressed repeated
+// supplies" event. Supplied events carrying an error are always logged.
+func WithSuppressRepeatSupplies() Option {
+	return func(l *LogrLogger) {
+		l.suppressRepeats = true
+	}
+}
+
+// WithFailedConstructor adds the best guess at the constructor that made an
+// invoke fail to failed Invoked events, under the "failed_constructor" key.
+// See failedConstructor for how it is found.
+func WithFailedConstructor() Option {
+	return func(l *LogrLogger) {
+		l.failedConstructor = true
+	}
+}
+
+// WithGrepPrefix starts every message with prefix, ahead of any symbol, so
+// that all fx logs can be found with a single grep. logr sinks write the
+// message before the key/value pairs, so the prefix comes before any field
+// of the event.
+func WithGrepPrefix(prefix string) Option {
+	return func(l *LogrLogger) {
+		l.grepPrefix = prefix
+	}
+}
+
+// WithExportedProvidesOnly limits Provided events to the types that look
+// exported, to audit the API surface of an application. A type looks exported
+// when its name, without pointers, slices, package path or fx annotations,
+// starts with an upper case letter; so "*pkg.Server" and `[]pkg.Handler[group="h"]`
+// are logged while "*pkg.server" and "string" are not. Provided events left
+// without any type are dropped, and failed ones are always logged.
+func WithExportedProvidesOnly() Option {
+	return func(l *LogrLogger) {
+		l.exportedOnly = true
+	}
+}
+
+// WithHookConcurrency adds the number of OnStart and OnStop hooks executing
+// at the time of a hook event under the "concurrent_hooks" key, to spot hooks
+// running concurrently. The count is taken under the logger's lock, so it is
+// safe to log hook events from several goroutines.
+func WithHookConcurrency() Option {
+	return func(l *LogrLogger) {
+		l.hookConcurrency = true
+	}
+}
+
+// WithCleanShutdownFlag logs a "shutdown" line when the application stops,
+// with "clean" set to whether no event reported an error since it started,
+// whether while starting or stopping.
+func WithCleanShutdownFlag() Option {
+	return func(l *LogrLogger) {
+		l.cleanShutdownFlag = true
+	}
+}
+
+// WithCategoryNames logs the events of each category with a logger named
+// after names[category], so that sinks can route them by logger name. The
+// categories are "wiring" for the events about building the dependency
+// graph, "lifecycle" for starting and stopping, and "logger" for the
+// LoggerInitialized event. The name is appended to the name of the logger,
+// so names of {"wiring": "wiring"} on a logger named "fx" log wiring events
+// as "fx/wiring" with funcr. Categories without a name use the logger as is.
+func WithCategoryNames(names map[string]string) Option {
+	return func(l *LogrLogger) {
+		l.categoryNames = make(map[string]string, len(names))
+		for category, name := range names {
+			l.categoryNames[category] = name
+		}
+	}
+}
+
+// WithStartAttempts stamps every event with the number of the start attempt
+// it belongs to under the "attempt" key, for applications that retry
+// fx.Start. An attempt begins with the first event after the application
+// stopped or failed to start, so the events of a rolled back start and of
+// the retry that follows it are told apart.
+func WithStartAttempts() Option {
+	return func(l *LogrLogger) {
+		l.startAttempts = true
+	}
+}
+
+// KeyNames are the keys of the fields of events.
+type KeyNames struct {
+	// Callee is the key of the function of a hook.
+	Callee string
+	// Caller is the key of the function that appended a hook.
+	Caller string
+	// Runtime is the key of how long a hook ran.
+	Runtime string
+	// TotalRuntime is the key of how long the OnStart hooks ran in total.
+	TotalRuntime string
+	// Phase is the key of whether a hook is an OnStart or an OnStop hook.
+	Phase string
+	// Type is the key of a supplied or output type.
+	Type string
+	// Types is the key of the output types when they are logged together.
+	Types string
+	// Module is the key of the module of an event.
+	Module string
+	// Constructor is the key of the constructor of a provided type.
+	Constructor string
+	// Decorator is the key of the decorator of a decorated type.
+	Decorator string
+	// Function is the key of an invoked function or logger constructor.
+	Function string
+	// Private is the key of whether a provided type is private.
+	Private string
+	// Stack is the key of the stack of an invoke failure.
+	Stack string
+	// Signal is the key of the signal that stopped the application.
+	Signal string
+	// SignalNumber is the key of the number of that signal.
+	SignalNumber string
+	// EventType is the key of the Go type of an event the logger does not
+	// handle.
+	EventType string
+}
+
+// DefaultKeyNames are the keys used unless WithKeyNames is used.
+var DefaultKeyNames = KeyNames{
+	Callee:       "callee",
+	Caller:       "caller",
+	Runtime:      "runtime",
+	TotalRuntime: "total_runtime",
+	Phase:        "phase",
+	Type:         "type",
+	Types:        "types",
+	Module:       "module",
+	Constructor:  "constructor",
+	Decorator:    "decorator",
+	Function:     "function",
+	Private:      "private",
+	Stack:        "stack",
+	Signal:       "signal",
+	SignalNumber: "signal_num",
+	EventType:    "event_type",
+}
+
+// WithKeyNames renames the fields of events to match the schema of a log
+// pipeline. Empty names keep their default from DefaultKeyNames.
+func WithKeyNames(names KeyNames) Option {
+	return func(l *LogrLogger) {
+		keys := DefaultKeyNames
+		mergeStrings(&keys, names)
+		l.keys = &keys
+	}
+}
+
+// Messages are the messages of events. Events that can fail have a message
+// for each outcome.
+type Messages struct {
+	OnStartExecuting           string
+	OnStartExecuted            string
+	OnStartFailed              string
+	OnStopExecuting            string
+	OnStopExecuted             string
+	OnStopFailed               string
+	Supplied                   string
+	SupplyFailed               string
+	Provided                   string
+	ProvideFailed              string
+	Replaced                   string
+	ReplaceFailed              string
+	Decorated                  string
+	DecorateFailed             string
+	Invoking                   string
+	InvokeFailed               string
+	Stopping                   string
+	StopFailed                 string
+	RollingBack                string
+	RollbackFailed             string
+	Started                    string
+	StartFailed                string
+	LoggerInitialized          string
+	LoggerInitializationFailed string
+	Unhandled                  string
+}
+
+// DefaultMessages are the messages used unless WithMessages is used.
+var DefaultMessages = Messages{
+	OnStartExecuting:           "OnStart hook executing",
+	OnStartExecuted:            "OnStart hook executed",
+	OnStartFailed:              "OnStart hook failed",
+	OnStopExecuting:            "OnStop hook executing",
+	OnStopExecuted:             "OnStop hook executed",
+	OnStopFailed:               "OnStop hook failed",
+	Supplied:                   "supplied",
+	SupplyFailed:               "error encountered while applying options",
+	Provided:                   "provided",
+	ProvideFailed:              "error encountered while applying options",
+	Replaced:                   "replaced",
+	ReplaceFailed:              "error encountered while replacing",
+	Decorated:                  "decorated",
+	DecorateFailed:             "error encountered while applying options",
+	Invoking:                   "invoking",
+	InvokeFailed:               "invoke failed",
+	Stopping:                   "received signal",
+	StopFailed:                 "stop failed",
+	RollingBack:                "start failed, rolling back",
+	RollbackFailed:             "rollback failed",
+	Started:                    "started",
+	StartFailed:                "start failed",
+	LoggerInitialized:          "initialized custom fxevent.Logger",
+	LoggerInitializationFailed: "custom logger initialization failed",
+	Unhandled:                  "unhandled fx event",
+}
+
+// WithMessages replaces the messages of events, for instance to localize or
+// shorten them. Empty messages keep their default from DefaultMessages. The
+// replaced messages are still subject to WithMessageCase and prefixes such as
+// WithSymbols.
+func WithMessages(messages Messages) Option {
+	return func(l *LogrLogger) {
+		m := DefaultMessages
+		mergeStrings(&m, messages)
+		l.messages = &m
+	}
+}
+
+// mergeStrings sets the string fields of the struct dst points to to the
+// non-empty ones of src, a struct of the same type.
+func mergeStrings(dst, src interface{}) {
+	d, v := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.String() != "" {
+			d.Field(i).SetString(f.String())
+		}
+	}
+}
+
+// WithErrorDigest logs a digest of the errors of the run when the application
+// stops: an "error digest" line for each group of errors sharing a
+// fingerprint, with the number of errors in the group and the message of the
+// first one. Errors have the same fingerprint when their messages only differ
+// by numbers. At most 10 groups are reported.
+func WithErrorDigest() Option {
+	return func(l *LogrLogger) {
+		l.errorDigest = true
+	}
+}
+
+// WithEventFilter only logs the events for which keep returns true. Unlike
+// WithEnableFunc, events filtered out are not tracked either, so they are not
+// part of summaries such as WithErrorSummary.
+func WithEventFilter(keep func(fxevent.Event) bool) Option {
+	return func(l *LogrLogger) {
+		l.eventFilter = keep
+	}
+}
+
+// OnlyEvents returns a filter for WithEventFilter keeping the events of the
+// same concrete types as events, as in OnlyEvents(&fxevent.Provided{}).
+func OnlyEvents(events ...fxevent.Event) func(fxevent.Event) bool {
+	types := eventTypes(events)
+	return func(event fxevent.Event) bool {
+		_, ok := types[reflect.TypeOf(event)]
+		return ok
+	}
+}
+
+// ExceptEvents returns a filter for WithEventFilter dropping the events of
+// the same concrete types as events.
+func ExceptEvents(events ...fxevent.Event) func(fxevent.Event) bool {
+	types := eventTypes(events)
+	return func(event fxevent.Event) bool {
+		_, ok := types[reflect.TypeOf(event)]
+		return !ok
+	}
+}
+
+func eventTypes(events []fxevent.Event) map[reflect.Type]struct{} {
+	types := make(map[reflect.Type]struct{}, len(events))
+	for _, event := range events {
+		types[reflect.TypeOf(event)] = struct{}{}
+	}
+	return types
+}
+
+// WithRedactor replaces the value of every field with a string value by what
+// redact returns for its key and value, for instance to hash internal type
+// names. The values of fields holding several strings, such as "types", are
+// redacted one by one. Error messages are not redacted.
+func WithRedactor(redact func(key, value string) string) Option {
+	return func(l *LogrLogger) {
+		l.redactor = redact
+	}
+}
+
+// DefaultInternalModules are the prefixes hidden by WithHideInternalModules
+// when none are given. fx reports its own constructors, such as the one of
+// fx.Lifecycle, without a module, so they are matched by their package.
+var DefaultInternalModules = []string{"go.uber.org/fx"}
+
+// WithHideInternalModules drops the non-error events of internal modules, to
+// keep logs focused on the application. An event is internal when its module
+// name, or the package path of its constructor, decorator or function, is one
+// of prefixes or is nested under one, like "fx/lifecycle" under "fx" or
+// "go.uber.org/fx.New.func1()" under "go.uber.org/fx". Without prefixes,
+// DefaultInternalModules are used.
+func WithHideInternalModules(prefixes ...string) Option {
+	return func(l *LogrLogger) {
+		if len(prefixes) == 0 {
+			prefixes = DefaultInternalModules
+		}
+		l.internalModules = append([]string(nil), prefixes...)
+	}
+}
+
+// WithEventKey stamps every line logged for an event with a key under the
+// "event_key" key, so that downstream consumers can drop duplicates. The key
+// is a hash of the run number, the position of the event in the run and its
+// content, followed by the index of the line among the lines of the event: it
+// is unique within a run and the same when the same events are logged again
+// by a new logger, as with ReplayBuffered. Lines derived from several events,
+// such as summaries, have no key.
+func WithEventKey() Option {
+	return func(l *LogrLogger) {
+		l.withEventKey = true
+	}
+}
+
+// WithNumericRuntime logs the runtime of hooks as a float64 number of unit,
+// such as time.Millisecond, instead of a duration string like "3ms".
+func WithNumericRuntime(unit time.Duration) Option {
+	return func(l *LogrLogger) {
+		l.runtimeUnit = unit
+	}
+}
+
+// WithAggregatedTypes, when aggregate is true, logs a single line for each
+// Provided, Replaced or Decorated event with all of its output types under
+// the "types" key, instead of a line per type under the "type" key.
+func WithAggregatedTypes(aggregate bool) Option {
+	return func(l *LogrLogger) {
+		l.aggregateTypes = aggregate
+	}
+}
+
+// WithInvokeStackTrace sets whether failed Invoked events carry the stack of
+// the invoke under the "stack" key, which can be large. It defaults to true.
+func WithInvokeStackTrace(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.noInvokeStack = !enabled
+	}
+}
+
+// WithName adds name to the name of the logger, as logr.Logger.WithName does,
+// to tell apart the logs of several fx applications in a process.
+func WithName(name string) Option {
+	return func(l *LogrLogger) {
+		named := l.Logger.WithName(name)
+		l.Logger = &named
+	}
+}
+
+// WithBaseValues adds keysAndValues to every line, as logr.Logger.WithValues
+// does, such as the name of the application or instance.
+func WithBaseValues(keysAndValues ...interface{}) Option {
+	return func(l *LogrLogger) {
+		enriched := l.Logger.WithValues(keysAndValues...)
+		l.Logger = &enriched
+	}
+}
+
+// WithLogUnknownEvents sets whether events of types unknown to this package,
+// such as events added by newer versions of fx, are logged as "unhandled fx
+// event" with their Go type under the "event_type" key, or the message and key
+// set by WithMessages and WithKeyNames. It defaults to true.
+func WithLogUnknownEvents(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.dropUnknown = !enabled
+	}
+}
+
+// WithEventObserver calls observe with every event and whether it carries an
+// error, for instance to count events in metrics. observe is called at the
+// start of LogEvent, before the event is dropped by WithEnableFunc,
+// WithEventFilter, sampling or throttling, so it sees every event.
+func WithEventObserver(observe func(event fxevent.Event, isError bool)) Option {
+	return func(l *LogrLogger) {
+		l.observer = observe
+	}
+}
+
+// WithRuntimeObserver calls observe with the event type, function name and
+// runtime of every OnStartExecuted and OnStopExecuted event, whether the hook
+// failed or not, for instance to feed a latency histogram keyed by hook. Like
+// WithEventObserver, it sees every event.
+func WithRuntimeObserver(observe func(eventType, name string, d time.Duration)) Option {
+	return func(l *LogrLogger) {
+		l.runtimeObserver = observe
+	}
+}
+
+// Sampler decides which events WithSampler logs.
+type Sampler interface {
+	// Sample reports whether event is logged and the rate it is sampled at,
+	// or 0 when the rate is not known.
+	Sample(event fxevent.Event) (keep bool, rate int)
+}
+
+// SamplerFunc is a Sampler logging the events for which it returns true, at
+// an unknown rate.
+type SamplerFunc func(event fxevent.Event) bool
+
+// Sample implements Sampler.
+func (f SamplerFunc) Sample(event fxevent.Event) (bool, int) {
+	return f(event), 0
+}
+
+// WithSampler only logs the events sampler keeps, such as EveryN. Events
+// carrying an error are always logged and not passed to sampler. Unlike
+// WithEventFilter, sampled out events are still tracked. Kept events sampled
+// at a known rate are annotated as with WithSampling, the rates multiplying
+// when both are used.
+func WithSampler(sampler Sampler) Option {
+	return func(l *LogrLogger) {
+		l.sampler = sampler
+	}
+}
+
+// everyN is the Sampler returned by EveryN.
+type everyN struct {
+	n      int
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// EveryN returns a Sampler for WithSampler keeping the first of every n
+// events of each type, like WithEventSampling does with the same rate for
+// every type. It is safe for concurrent use, so it can be shared by several
+// loggers.
+func EveryN(n int) Sampler {
+	return &everyN{n: n, counts: make(map[string]int)}
+}
+
+func (s *everyN) Sample(event fxevent.Event) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	typ := eventType(event)
+	s.counts[typ]++
+	return keepSample(s.n, s.counts[typ]), s.n
+}
+
+// WithSignalNumber, when enabled is true, adds the number of the signal that
+// stopped the application under the "signal_num" key, next to its name. It is
+// omitted for signals that are not a syscall.Signal.
+func WithSignalNumber(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.signalNumber = enabled
+	}
+}
+
+// WithRuntimePrecision rounds the runtime of hooks to a multiple of d, as
+// time.Duration.Round does, so that "3.214998ms" is logged as "3ms" with a
+// precision of time.Millisecond. A precision of zero, the default, keeps
+// runtimes as they are.
+func WithRuntimePrecision(d time.Duration) Option {
+	return func(l *LogrLogger) {
+		l.runtimePrecision = d
+	}
+}
+
+// WithExplicitPrivate, when explicit is true, always logs the "private" key
+// of Provided events, set to false for public constructors. By default, it
+// is only logged for private constructors.
+func WithExplicitPrivate(explicit bool) Option {
+	return func(l *LogrLogger) {
+		l.explicitPrivate = explicit
+	}
+}
+
+// WithContextValues adds the key/value pairs extract returns for the context
+// passed to LogEventCtx to the lines of the event, for instance to correlate
+// fx logs with the trace of a request. LogEvent uses context.Background().
+func WithContextValues(extract func(context.Context) []interface{}) Option {
+	return func(l *LogrLogger) {
+		l.contextValues = extract
+	}
+}
+
+// WithErrorPolicy decides how the error lines of events are logged: with
+// logr.Logger.Error at the given level when policy returns true, or as
+// regular lines at the given level with the error message under the "error"
+// key otherwise. Either way, the error is counted and decorated by the other
+// error options. Without a policy, errors are logged with Error at the error
+// level.
+func WithErrorPolicy(policy func(fxevent.Event) (useError bool, level int)) Option {
+	return func(l *LogrLogger) {
+		l.errorPolicy = policy
+	}
+}
+
+// WithStartupSummary, when enabled is true, logs a "fx startup summary" line
+// once the application started, with the number of Provided, Decorated and
+// Invoked events of the run and the time elapsed since its first event under
+// the "startup_time" key.
+func WithStartupSummary(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.startupSummary = enabled
+	}
+}
+
+// WithTypeFormatter passes the type, constructor and decorator names of
+// Supplied, Provided, Replaced and Decorated events through format before
+// they are logged, for instance to shorten package paths, including in the
+// lines of WithTypeDiff and WithModuleProvideSummary. It applies after
+// WithFQTypeNames.
+func WithTypeFormatter(format func(string) string) Option {
+	return func(l *LogrLogger) {
+		l.typeFormatter = format
+	}
+}
+
+// WithPhaseField adds a "phase" key of "start" or "stop" to the lines of
+// OnStart and OnStop hook events, so that they can be told apart without
+// matching on the message.
+func WithPhaseField(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.phaseField = enabled
+	}
+}
+
+// WithErrorWrapping wraps the errors of error events with the context of the
+// event before logging them, as in "fx OnStart hook main.start failed: ...".
+// The wrapped errors unwrap to the original ones, so errors.Is and errors.As
+// keep working on them in sinks and hooks. The errors WithSplitMultierror
+// splits a wrapped error into are each wrapped with the same context.
+func WithErrorWrapping(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.errorWrapping = enabled
+	}
+}
+
+// WithErrorChain adds an "errors" key to error events listing the messages of
+// the errors the logged error is made of, found by unwrapping it and splitting
+// multierrors, such as the several errors of a failed provide. The "error" key
+// keeps the message of the logged error itself.
+func WithErrorChain(enabled bool) Option {
+	return func(l *LogrLogger) {
+		l.errorChain = enabled
+	}
+}
+
+// WithWriteErrorHandler calls handle with the errors of loggers writing to a
+// connection, such as NewSyslog, failing to open, write to or close it. The
+// errors are dropped otherwise, since a logger has nowhere else to report
+// them.
+func WithWriteErrorHandler(handle func(error)) Option {
+	return func(l *LogrLogger) {
+		l.writeErrorHandler = handle
+	}
+}
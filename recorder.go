@@ -0,0 +1,175 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/fx/fxevent"
+)
+
+// Record is a log line captured by a LogRecorder.
+type Record struct {
+	// Level is the V-level of the line. logr does not pass the level of
+	// error lines to its sinks, so it is always 0 for them.
+	Level int
+	// Name is the name of the logger the line was logged with.
+	Name string
+	// Msg is the message of the line.
+	Msg string
+	// KeysAndValues are the key/value pairs of the line, including those
+	// added with WithValues.
+	KeysAndValues []interface{}
+	// Err is the error of an error line.
+	Err error
+	// IsError is set for lines logged with Error.
+	IsError bool
+}
+
+// LogRecorder captures the lines logged through its Logger, with their level,
+// so that tests can assert on what was logged and at which level.
+type LogRecorder struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewLogRecorder returns an empty LogRecorder.
+func NewLogRecorder() *LogRecorder {
+	return &LogRecorder{}
+}
+
+// Logger returns a logr.Logger recording into r. All levels are enabled.
+func (r *LogRecorder) Logger() logr.Logger {
+	return logr.New(&recorderSink{recorder: r})
+}
+
+// Records returns a copy of the captured lines, in the order they were logged.
+func (r *LogRecorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]Record, len(r.records))
+	copy(records, r.records)
+	return records
+}
+
+func (r *LogRecorder) record(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, rec)
+}
+
+// recorderSink is the logr.LogSink behind LogRecorder.Logger.
+type recorderSink struct {
+	recorder *LogRecorder
+	name     string
+	values   []interface{}
+}
+
+var _ logr.LogSink = (*recorderSink)(nil)
+
+func (s *recorderSink) Init(logr.RuntimeInfo) {}
+
+func (s *recorderSink) Enabled(int) bool {
+	return true
+}
+
+func (s *recorderSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.recorder.record(Record{
+		Level:         level,
+		Name:          s.name,
+		Msg:           msg,
+		KeysAndValues: s.keysAndValues(keysAndValues),
+	})
+}
+
+func (s *recorderSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.recorder.record(Record{
+		Name:          s.name,
+		Msg:           msg,
+		KeysAndValues: s.keysAndValues(keysAndValues),
+		Err:           err,
+		IsError:       true,
+	})
+}
+
+func (s *recorderSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	clone := *s
+	clone.values = s.keysAndValues(keysAndValues)
+	return &clone
+}
+
+func (s *recorderSink) WithName(name string) logr.LogSink {
+	clone := *s
+	if clone.name != "" {
+		clone.name += "/"
+	}
+	clone.name += name
+	return &clone
+}
+
+func (s *recorderSink) keysAndValues(keysAndValues []interface{}) []interface{} {
+	if len(s.values) == 0 {
+		return keysAndValues
+	}
+	return append(append([]interface{}{}, s.values...), keysAndValues...)
+}
+
+// RecordingLogger is a fxevent.Logger that records the events logged to it,
+// so that tests can assert on the lifecycle events of an fx application
+// without going through a logr.Logger.
+type RecordingLogger struct {
+	mu     sync.Mutex
+	events []fxevent.Event
+}
+
+var _ fxevent.Logger = (*RecordingLogger)(nil)
+
+// NewRecorder returns an empty RecordingLogger.
+func NewRecorder() *RecordingLogger {
+	return &RecordingLogger{}
+}
+
+// LogEvent records event.
+func (r *RecordingLogger) LogEvent(event fxevent.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+// Events returns a copy of the recorded events, in the order they were logged.
+func (r *RecordingLogger) Events() []fxevent.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]fxevent.Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// EventsOfType returns the events of type T recorded by r, in the order they
+// were logged, as in EventsOfType[*fxevent.Provided](r).
+func EventsOfType[T fxevent.Event](r *RecordingLogger) []T {
+	var events []T
+	for _, event := range r.Events() {
+		if e, ok := event.(T); ok {
+			events = append(events, e)
+		}
+	}
+	return events
+}
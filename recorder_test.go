@@ -0,0 +1,72 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestLogRecorder(t *testing.T) {
+	recorder := NewLogRecorder()
+	l := recorder.Logger()
+	logger := WithLogr(&l, WithModulePattern("internal/*", 3))().(*LogrLogger)
+	logger.UseLogLevel(1)
+
+	someError := errors.New("some error")
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "main.run()"})
+	logger.LogEvent(&fxevent.Invoking{FunctionName: "db.New()", ModuleName: "internal/db"})
+	logger.LogEvent(&fxevent.Started{Err: someError})
+
+	assert.Equal(t, []Record{
+		{Level: 1, Msg: "invoking", KeysAndValues: []interface{}{"function", "main.run()"}},
+		{Level: 3, Msg: "invoking", KeysAndValues: []interface{}{"function", "db.New()", "module", "internal/db"}},
+		{Msg: "start failed", Err: someError, IsError: true},
+	}, recorder.Records())
+}
+
+func TestLogRecorderNameAndValues(t *testing.T) {
+	recorder := NewLogRecorder()
+	recorder.Logger().WithName("fx").WithValues("app", "gateway").Info("started")
+
+	assert.Equal(t, []Record{
+		{Name: "fx", Msg: "started", KeysAndValues: []interface{}{"app", "gateway"}},
+	}, recorder.Records())
+}
+
+func TestRecordingLogger(t *testing.T) {
+	recorder := NewRecorder()
+	app := fx.New(
+		fx.WithLogger(func() fxevent.Logger { return recorder }),
+		fx.Provide(func() *bytes.Buffer { return &bytes.Buffer{} }),
+		fx.Invoke(func(*bytes.Buffer) {}),
+	)
+	require.NoError(t, app.Err())
+
+	assert.NotEmpty(t, recorder.Events())
+	assert.Len(t, EventsOfType[*fxevent.LoggerInitialized](recorder), 1)
+
+	provided := EventsOfType[*fxevent.Provided](recorder)
+	require.NotEmpty(t, provided)
+	assert.Equal(t, []string{"*bytes.Buffer"}, provided[0].OutputTypeNames)
+	assert.Len(t, EventsOfType[*fxevent.Invoked](recorder), 1)
+	assert.Empty(t, EventsOfType[*fxevent.Started](recorder))
+}
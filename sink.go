@@ -0,0 +1,240 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/fx/fxevent"
+)
+
+// Format is the line format a Sink writes events in.
+type Format int
+
+const (
+	// FormatLogfmt writes events as logfmt key=value pairs.
+	FormatLogfmt Format = iota
+	// FormatJSON writes events as JSON objects.
+	FormatJSON
+)
+
+// Sink is a destination for events logged through WithSinks.
+type Sink struct {
+	// Writer receives one line per log entry.
+	Writer io.Writer
+	// Format is the format of the lines written to Writer.
+	Format Format
+	// Verbosity is the highest V-level written to Writer.
+	Verbosity int
+}
+
+// WithSinks returns a function that returns a fxevent.Logger writing every
+// event to all of the given sinks, each in its own format.
+func WithSinks(sinks ...Sink) func() fxevent.Logger {
+	writers := make([]logr.LogSink, len(sinks))
+	for i, s := range sinks {
+		writers[i] = &writerSink{sink: s, mu: &sync.Mutex{}}
+	}
+	l := logr.New(multiSink(writers))
+	return WithLogr(&l)
+}
+
+// multiSink is a logr.LogSink that forwards to all of its sinks.
+type multiSink []logr.LogSink
+
+var _ logr.LogSink = multiSink(nil)
+
+func (m multiSink) Init(info logr.RuntimeInfo) {
+	for _, s := range m {
+		s.Init(info)
+	}
+}
+
+func (m multiSink) Enabled(level int) bool {
+	for _, s := range m {
+		if s.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	for _, s := range m {
+		if s.Enabled(level) {
+			s.Info(level, msg, keysAndValues...)
+		}
+	}
+}
+
+func (m multiSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	for _, s := range m {
+		s.Error(err, msg, keysAndValues...)
+	}
+}
+
+func (m multiSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	sinks := make(multiSink, len(m))
+	for i, s := range m {
+		sinks[i] = s.WithValues(keysAndValues...)
+	}
+	return sinks
+}
+
+func (m multiSink) WithName(name string) logr.LogSink {
+	sinks := make(multiSink, len(m))
+	for i, s := range m {
+		sinks[i] = s.WithName(name)
+	}
+	return sinks
+}
+
+// writerSink is a logr.LogSink writing formatted lines to a Sink.
+type writerSink struct {
+	sink   Sink
+	name   string
+	values []interface{}
+
+	// mu is shared by all sinks derived from the same Sink, so that lines
+	// written to its Writer never interleave.
+	mu *sync.Mutex
+}
+
+var _ logr.LogSink = (*writerSink)(nil)
+
+func (w *writerSink) Init(logr.RuntimeInfo) {}
+
+func (w *writerSink) Enabled(level int) bool {
+	return level <= w.sink.Verbosity
+}
+
+func (w *writerSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	w.write([]interface{}{"level", level, "msg", msg}, keysAndValues)
+}
+
+func (w *writerSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	var errMsg interface{}
+	if err != nil {
+		errMsg = err.Error()
+	}
+	w.write([]interface{}{"msg", msg, "error", errMsg}, keysAndValues)
+}
+
+func (w *writerSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	clone := *w
+	clone.values = append(append([]interface{}{}, w.values...), keysAndValues...)
+	return &clone
+}
+
+func (w *writerSink) WithName(name string) logr.LogSink {
+	clone := *w
+	if clone.name != "" {
+		clone.name += "/"
+	}
+	clone.name += name
+	return &clone
+}
+
+func (w *writerSink) write(prefix, keysAndValues []interface{}) {
+	kvs := make([]interface{}, 0, 2+len(prefix)+len(w.values)+len(keysAndValues))
+	if w.name != "" {
+		kvs = append(kvs, "logger", w.name)
+	}
+	kvs = append(kvs, prefix...)
+	kvs = append(kvs, w.values...)
+	kvs = append(kvs, keysAndValues...)
+
+	var line []byte
+	switch w.sink.Format {
+	case FormatJSON:
+		line = formatJSON(kvs)
+	default:
+		line = formatLogfmt(kvs)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = w.sink.Writer.Write(line)
+}
+
+// formatJSON formats keysAndValues as a JSON object, keeping their order.
+func formatJSON(keysAndValues []interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(keysAndValues[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		var v interface{}
+		if i+1 < len(keysAndValues) {
+			v = keysAndValues[i+1]
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(v))
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// formatLogfmt formats keysAndValues as logfmt key=value pairs, quoting
+// values when needed.
+func formatLogfmt(keysAndValues []interface{}) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(fmt.Sprint(keysAndValues[i]))
+		buf.WriteByte('=')
+		var v interface{}
+		if i+1 < len(keysAndValues) {
+			v = keysAndValues[i+1]
+		}
+		buf.WriteString(logfmtValue(v))
+	}
+	return buf.Bytes()
+}
+
+func logfmtValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case []string:
+		s = strings.Join(v, ",")
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
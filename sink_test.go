@@ -0,0 +1,62 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithSinks(t *testing.T) {
+	var jsonBuf, logfmtBuf bytes.Buffer
+	logger := WithSinks(
+		Sink{Writer: &jsonBuf, Format: FormatJSON},
+		Sink{Writer: &logfmtBuf, Format: FormatLogfmt},
+	)()
+
+	logger.LogEvent(&fxevent.Provided{
+		ConstructorName: "bytes.NewBuffer()",
+		ModuleName:      "myModule",
+		OutputTypeNames: []string{"*bytes.Buffer"},
+	})
+	logger.LogEvent(&fxevent.Stopped{Err: errors.New("some error")})
+
+	assert.Equal(t,
+		`{"level":0,"msg":"provided","constructor":"bytes.NewBuffer()","module":"myModule","type":"*bytes.Buffer"}`+"\n"+
+			`{"msg":"stop failed","error":"some error"}`+"\n",
+		jsonBuf.String())
+	assert.Equal(t,
+		`level=0 msg=provided constructor=bytes.NewBuffer() module=myModule type=*bytes.Buffer`+"\n"+
+			`msg="stop failed" error="some error"`+"\n",
+		logfmtBuf.String())
+}
+
+func TestWithSinksVerbosity(t *testing.T) {
+	var quiet, verbose bytes.Buffer
+	logger := WithSinks(
+		Sink{Writer: &quiet},
+		Sink{Writer: &verbose, Verbosity: 1},
+	)().(*LogrLogger)
+	logger.UseLogLevel(1)
+
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Empty(t, quiet.String())
+	assert.Equal(t, "level=1 msg=started total_runtime=0s\n", verbose.String())
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/fx/fxevent"
+)
+
+// Syslog severities of events, by class.
+const (
+	syslogSeverityError     = 3 // err
+	syslogSeverityLifecycle = 5 // notice
+	syslogSeverityWiring    = 6 // info
+)
+
+// syslogFacility is the user-level messages facility.
+const syslogFacility = 1
+
+// syslogSDID is the ID of the structured data element holding the fields of
+// an event, under the documentation private enterprise number.
+const syslogSDID = "fx@32473"
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// WithSyslog returns a function that returns a SyslogLogger connected to the
+// syslog server at addr on network, for use with fx.WithLogger. See
+// NewSyslog.
+func WithSyslog(network, addr, tag string, opts ...Option) func() (fxevent.Logger, error) {
+	return func() (fxevent.Logger, error) {
+		s, err := NewSyslog(network, addr, tag, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// SyslogLogger is a fxevent.Logger writing every event to a syslog server as
+// an RFC 5424 line. The message ID of a line is the fxevent type name, its
+// message is the message LogEvent would log, and its structured data holds
+// the fields LogEvent would log. Errors have severity err, lifecycle events
+// notice and the other events info.
+//
+// The connection is closed at the end of a run, on Stopped or a failed
+// Started, and opened again by the next event. Close closes it otherwise,
+// such as when fx.New fails.
+type SyslogLogger struct {
+	mu       sync.Mutex
+	network  string
+	addr     string
+	conn     net.Conn
+	hostname string
+	tag      string
+	pid      int
+	mapper   *LogrLogger
+}
+
+var _ fxevent.Logger = (*SyslogLogger)(nil)
+
+// NewSyslog connects to the syslog server at addr on network, as with
+// net.Dial, and returns a SyslogLogger writing to it with tag. opts configure
+// the fields and messages of the lines, as with WithKeyNames and
+// WithMessages, and WithWriteErrorHandler receives the errors of writing
+// them.
+func NewSyslog(network, addr, tag string, opts ...Option) (*SyslogLogger, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return &SyslogLogger{
+		network:  network,
+		addr:     addr,
+		conn:     conn,
+		hostname: hostname,
+		tag:      tag,
+		pid:      os.Getpid(),
+		mapper:   New(nil, opts...),
+	}, nil
+}
+
+// Close closes the connection to the syslog server, if open.
+func (s *SyslogLogger) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.close()
+}
+
+func (s *SyslogLogger) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// LogEvent writes event to the syslog server.
+func (s *SyslogLogger) LogEvent(event fxevent.Event) {
+	typ := eventType(event)
+	severity := syslogSeverityWiring
+	if eventCategory(event) == categoryLifecycle {
+		severity = syslogSeverityLifecycle
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.Dial(s.network, s.addr)
+		if err != nil {
+			s.mapper.writeError(err)
+			return
+		}
+		s.conn = conn
+	}
+	for _, e := range s.mapper.entries(event) {
+		sev := severity
+		kvs := e.keysAndValues
+		if e.isError {
+			sev = syslogSeverityError
+			if e.err != nil {
+				kvs = append([]interface{}{"error", e.err.Error()}, kvs...)
+			}
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "<%d>1 %s %s %s %d %s ",
+			syslogFacility*8+sev,
+			time.Now().UTC().Format(time.RFC3339Nano),
+			s.hostname, s.tag, s.pid, typ)
+		if len(kvs) == 0 {
+			sb.WriteString("-")
+		} else {
+			sb.WriteString("[" + syslogSDID)
+			for i := 0; i+1 < len(kvs); i += 2 {
+				fmt.Fprintf(&sb, ` %v="%s"`, kvs[i], syslogParamEscaper.Replace(fieldString(kvs[i+1])))
+			}
+			sb.WriteString("]")
+		}
+		sb.WriteString(" " + e.msg + "\n")
+
+		if _, err := io.WriteString(s.conn, sb.String()); err != nil {
+			s.mapper.writeError(err)
+		}
+	}
+
+	switch e := event.(type) {
+	case *fxevent.Stopped:
+		s.mapper.writeError(s.close())
+	case *fxevent.Started:
+		if e.Err != nil {
+			s.mapper.writeError(s.close())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithSyslog(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	logger, err := WithSyslog("udp", server.LocalAddr().String(), "app")()
+	require.NoError(t, err)
+	defer logger.(*SyslogLogger).Close()
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Invoked{FunctionName: "main.run()", Err: errors.New(`bad "value"]`)})
+
+	var lines []string
+	buf := make([]byte, 4096)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, server.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := server.ReadFrom(buf)
+		require.NoError(t, err)
+		lines = append(lines, string(buf[:n]))
+	}
+
+	hostname, _ := os.Hostname()
+	want := []string{
+		`<14>1 %s app %d Provided [fx@32473 constructor="bytes.NewBuffer()" type="*bytes.Buffer"] provided`,
+		`<13>1 %s app %d Started - started`,
+		`<11>1 %s app %d Invoked [fx@32473 error="bad \"value\"\]" stack="" function="main.run()"] invoke failed`,
+	}
+	for i, line := range lines {
+		// Drop the timestamp.
+		fields := strings.SplitN(line, " ", 3)
+		assert.Equal(t, fmt.Sprintf(want[i], hostname, os.Getpid())+"\n", fields[0]+" "+fields[2])
+	}
+}
+
+func TestWithSyslogDialError(t *testing.T) {
+	_, err := WithSyslog("invalid", "", "app")()
+	assert.Error(t, err)
+}
+
+func TestSyslogLoggerClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	var errs []error
+	logger, err := NewSyslog("tcp", listener.Addr().String(), "app", WithWriteErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	require.NoError(t, err)
+	defer logger.Close()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Stopped ends the run and closes the connection.
+	logger.LogEvent(&fxevent.Started{})
+	logger.LogEvent(&fxevent.Stopped{})
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), " Started - started\n")
+
+	// The next event opens the connection again, and fails to with the
+	// listener closed.
+	require.NoError(t, listener.Close())
+	logger.LogEvent(&fxevent.Started{})
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "connection refused")
+
+	assert.NoError(t, logger.Close())
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "time"
+
+// TimelineEntry is an event recorded by WithTimeline.
+type TimelineEntry struct {
+	// EventType is the fxevent type name of the event, such as "Provided".
+	EventType string
+	// Time is when the event was received.
+	Time time.Time
+	// Duration is the runtime reported by OnStartExecuted and OnStopExecuted
+	// events, and 0 for other events.
+	Duration time.Duration
+}
+
+// Timeline returns the events of the current run recorded by WithTimeline, in
+// the order they were received. Once Started is received, it holds the whole
+// startup of the application.
+func (l *LogrLogger) Timeline() []TimelineEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	timeline := make([]TimelineEntry, len(l.run.timeline))
+	copy(timeline, l.run.timeline)
+	return timeline
+}
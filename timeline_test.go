@@ -0,0 +1,51 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestTimeline(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	l, lines := newCapturingLogr()
+	logger := WithLogr(l, WithTimeline(), WithClock(clock.Now))().(*LogrLogger)
+
+	logger.LogEvent(&fxevent.Provided{ConstructorName: "bytes.NewBuffer()", OutputTypeNames: []string{"*bytes.Buffer"}})
+	clock.Add(time.Millisecond)
+	logger.LogEvent(&fxevent.OnStartExecuting{FunctionName: "hook.onStart", CallerName: "bytes.NewBuffer"})
+	clock.Add(3 * time.Millisecond)
+	logger.LogEvent(&fxevent.OnStartExecuted{FunctionName: "hook.onStart", CallerName: "bytes.NewBuffer", Runtime: 3 * time.Millisecond})
+	logger.LogEvent(&fxevent.Started{})
+
+	assert.Len(t, *lines, 4)
+	assert.Equal(t, []TimelineEntry{
+		{EventType: "Provided", Time: start},
+		{EventType: "OnStartExecuting", Time: start.Add(time.Millisecond)},
+		{EventType: "OnStartExecuted", Time: start.Add(4 * time.Millisecond), Duration: 3 * time.Millisecond},
+		{EventType: "Started", Time: start.Add(4 * time.Millisecond)},
+	}, logger.Timeline())
+
+	logger.LogEvent(&fxevent.Started{Err: assert.AnError})
+	logger.LogEvent(&fxevent.Supplied{TypeName: "int"})
+	assert.Equal(t, []TimelineEntry{
+		{EventType: "Supplied", Time: start.Add(4 * time.Millisecond)},
+	}, logger.Timeline())
+}
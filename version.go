@@ -0,0 +1,51 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import "runtime/debug"
+
+// modulePath is the import path of this module.
+const modulePath = "github.com/chaos-mesh/fx-logr"
+
+// readBuildInfo is replaced in tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// adapterVersion returns the version of this module the binary was built
+// with, "(devel)" when it is replaced by a directory, or "unknown" when it is
+// not recorded in the build info.
+func adapterVersion() string {
+	info, ok := readBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			// Replacements by a directory have no version, like the main
+			// module built from its source tree.
+			if dep.Replace.Version == "" {
+				return "(devel)"
+			}
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
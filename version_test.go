@@ -0,0 +1,70 @@
+// Copyright 2023 Chaos Mesh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fxlogr
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestWithAdapterVersion(t *testing.T) {
+	defer func(f func() (*debug.BuildInfo, bool)) { readBuildInfo = f }(readBuildInfo)
+
+	tests := []struct {
+		name        string
+		give        *debug.BuildInfo
+		wantVersion string
+	}{
+		{
+			name: "Dependency",
+			give: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.2.0"}},
+			},
+			wantVersion: "v0.2.0",
+		},
+		{
+			name: "Replaced",
+			give: &debug.BuildInfo{
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.2.0", Replace: &debug.Module{Version: "v0.2.1"}}},
+			},
+			wantVersion: "v0.2.1",
+		},
+		{
+			name: "ReplacedByDirectory",
+			give: &debug.BuildInfo{
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.2.0", Replace: &debug.Module{Path: "../fx-logr"}}},
+			},
+			wantVersion: "(devel)",
+		},
+		{
+			name:        "Missing",
+			give:        &debug.BuildInfo{},
+			wantVersion: "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		readBuildInfo = func() (*debug.BuildInfo, bool) { return tt.give, true }
+
+		l, lines := newCapturingLogr()
+		WithLogr(l, WithAdapterVersion())().LogEvent(&fxevent.Started{})
+
+		assert.Equal(t, []string{"\"level\"=0 \"msg\"=\"started\" \"total_runtime\"=\"0s\" \"adapter_version\"=\"" + tt.wantVersion + "\""}, *lines, tt.name)
+	}
+}